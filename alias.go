@@ -0,0 +1,205 @@
+package swgen
+
+import (
+	"fmt"
+	"html/template"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// RedirectsFile is the redirect map of the aliases in the output directory,
+// each line is "<alias url> <page url> 301"
+const RedirectsFile = "_redirects"
+
+var aliasTemplate = template.Must(template.New("alias").Parse(`<!DOCTYPE html>
+<html>
+  <head>
+    <title>{{.URL}}</title>
+    <link rel="canonical" href="{{.URL}}" />
+    <meta http-equiv="refresh" content="0; url={{.URL}}" />
+  </head>
+  <body>
+    <p>This page has moved to <a href="{{.URL}}">{{.URL}}</a>.</p>
+  </body>
+</html>
+`))
+
+// Aliases is the old paths of the node from the "aliases" param, they are
+// relative to the source directory, such as notes/old.org or old/dir/
+func (n *Node) Aliases() []string {
+	return n.Params.Strings("aliases")
+}
+
+// aliasFile is the output file of the alias, it is generated as if a page
+// lived at the alias path. The alias must stay within the source directory.
+func (sw *Swgen) aliasFile(alias string) (string, error) {
+	rel := filepath.Clean(filepath.FromSlash(strings.TrimPrefix(alias, "/")))
+	if rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) || filepath.IsAbs(rel) {
+		return "", fmt.Errorf("alias %s is outside the site", alias)
+	}
+	path := filepath.Join(sw.Source, rel)
+	if strings.HasSuffix(alias, "/") {
+		return filepath.Join(sw.MustGetTargetPath(path), "index.html"), nil
+	}
+	return sw.pageFile(path), nil
+}
+
+// aliasesFile records the redirect stubs in the state directory, the stubs
+// of the removed aliases are deleted on the next build
+const aliasesFile = ".swaliases"
+
+// alias is a redirect stub of a node
+type alias struct {
+	n    *Node
+	name string
+	dest string
+}
+
+// outputs is the files written for the tree other than the aliases: the
+// output files of the nodes, the attachments, the formats and the feeds,
+// mapped to what they are written for
+func (sw *Swgen) outputs(tree *Node) (map[string]string, error) {
+	files := map[string]string{}
+	formats := sw.outputFormats()
+	tree.Walk(func(n *Node) error {
+		files[sw.TargetFile(n)] = "page " + n.path
+		for _, a := range n.Attachments {
+			files[sw.MustGetTargetPath(a.path)] = "attachment " + a.path
+		}
+		for _, f := range formats {
+			if f.enabled(n) {
+				files[sw.outputFile(n, f)] = fmt.Sprintf("output %s of %s", f.Name, n.path)
+			}
+		}
+		return nil
+	})
+
+	feeds, err := sw.feedFiles(tree)
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range feeds {
+		files[file] = "feed " + file
+	}
+	return files, nil
+}
+
+// renderAliases writes a redirect stub for each alias and the redirect map,
+// the map is left out if the site has no aliases. All the aliases are
+// checked before any stub is written.
+func (sw *Swgen) renderAliases(tree *Node) error {
+	files, err := sw.outputs(tree)
+	if err != nil {
+		return err
+	}
+
+	aliases := []*alias{}
+	stubs := map[string]*Node{}
+	err = tree.Walk(func(n *Node) error {
+		for _, name := range n.Aliases() {
+			dest, err := sw.aliasFile(name)
+			if err != nil {
+				return fmt.Errorf("%s of %s", err, n.path)
+			}
+			if other, ok := files[dest]; ok {
+				return fmt.Errorf("alias %s of %s collides with %s", name, n.path, other)
+			}
+			if other, ok := stubs[dest]; ok {
+				return fmt.Errorf("alias %s of %s collides with an alias of %s", name, n.path, other.path)
+			}
+			stubs[dest] = n
+			aliases = append(aliases, &alias{n: n, name: name, dest: dest})
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	redirects := []string{}
+	written := map[string]bool{}
+	for _, a := range aliases {
+		from, err := sw.fileURL(a.dest)
+		if err != nil {
+			return err
+		}
+		to, err := a.n.PageURL()
+		if err != nil {
+			return err
+		}
+		stub := to
+		if sw.RelativeURLs {
+			stub = sw.relativeURL(to, a.dest)
+		}
+		if err := renderAlias(a.dest, stub); err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(sw.Target, a.dest)
+		if err != nil {
+			return err
+		}
+		written[filepath.ToSlash(rel)] = true
+		redirects = append(redirects, fmt.Sprintf("%s %s 301\n", from, to))
+	}
+	if err := sw.removeStaleAliases(files, written); err != nil {
+		return err
+	}
+
+	dest := filepath.Join(sw.Target, RedirectsFile)
+	if len(redirects) == 0 {
+		if err := os.Remove(dest); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+
+	sort.Strings(redirects)
+	fd, err := os.Create(dest)
+	if err != nil {
+		return err
+	}
+	defer fd.Close()
+
+	_, err = fd.WriteString(strings.Join(redirects, ""))
+	return err
+}
+
+// removeStaleAliases deletes the stubs of the last build which are not
+// written again, unless another output took the file over, and records
+// the written stubs
+func (sw *Swgen) removeStaleAliases(files map[string]string, written map[string]bool) error {
+	record := filepath.Join(sw.stateDir(), aliasesFile)
+	last, err := loadUsers(record)
+	if err != nil {
+		return err
+	}
+	for rel := range last {
+		dest := filepath.Join(sw.Target, filepath.FromSlash(rel))
+		if _, ok := files[dest]; ok || written[rel] {
+			continue
+		}
+		log.Printf("remove redirect %s", dest)
+		if err := os.Remove(dest); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return saveUsers(record, written)
+}
+
+func renderAlias(dest, url string) error {
+	log.Printf("redirect %s to %s", dest, url)
+	if err := os.MkdirAll(filepath.Dir(dest), os.ModePerm); err != nil {
+		return err
+	}
+
+	fd, err := os.Create(dest)
+	if err != nil {
+		return err
+	}
+	defer fd.Close()
+
+	return aliasTemplate.Execute(fd, struct{ URL string }{url})
+}
@@ -0,0 +1,81 @@
+package swgen
+
+import (
+	"html/template"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAliases(t *testing.T) {
+	source := makeSite(t, map[string]string{
+		"notes/new.html": "---\naliases: [old.html, /archive/old/]\n---\n<p>new</p>",
+		"other.html":     "<p>other</p>",
+	})
+	defer os.RemoveAll(source)
+
+	sw := Swgen{
+		Source:   source,
+		Target:   filepath.Join(source, "testing"),
+		URLRoot:  "wiki",
+		Ignore:   &dummyIgnore{},
+		Template: template.Must(template.New("page").Parse(`{{.Page}}`)),
+	}
+	assert.NoError(t, sw.Run())
+
+	stub, err := ioutil.ReadFile(filepath.Join(sw.Target, "old.html.html"))
+	assert.NoError(t, err)
+	assert.Contains(t, string(stub), `<link rel="canonical" href="/wiki/notes/new.html.html" />`)
+	assert.Contains(t, string(stub), `content="0; url=/wiki/notes/new.html.html"`)
+
+	_, err = os.Stat(filepath.Join(sw.Target, "archive/old/index.html"))
+	assert.NoError(t, err)
+
+	redirects, err := ioutil.ReadFile(filepath.Join(sw.Target, RedirectsFile))
+	assert.NoError(t, err)
+	assert.Equal(t, "/wiki/archive/old/ /wiki/notes/new.html.html 301\n"+
+		"/wiki/old.html.html /wiki/notes/new.html.html 301\n", string(redirects))
+
+	// an alias must not replace a real page
+	page := filepath.Join(source, "notes/new.html")
+	assert.NoError(t, ioutil.WriteFile(page, []byte("---\naliases: [other.html]\n---\n"), 0644))
+	err = sw.Run()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "collides with page")
+	}
+
+	// nor escape the output directory
+	for _, alias := range []string{"../../x.html", "/../etc/x"} {
+		assert.NoError(t, ioutil.WriteFile(page, []byte("---\naliases: ["+alias+"]\n---\n"), 0644))
+		err = sw.Run()
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), "outside the site")
+		}
+	}
+
+	// the redirect map is left out without aliases
+	assert.NoError(t, ioutil.WriteFile(page, []byte("<p>new</p>"), 0644))
+	assert.NoError(t, sw.Run())
+	_, err = os.Stat(filepath.Join(sw.Target, RedirectsFile))
+	assert.True(t, os.IsNotExist(err))
+
+	// and the stubs of the removed aliases are deleted
+	for _, stub := range []string{"old.html.html", "archive/old/index.html"} {
+		_, err = os.Stat(filepath.Join(sw.Target, stub))
+		assert.True(t, os.IsNotExist(err), stub)
+	}
+
+	// an alias must not replace a feed either, nothing is written then
+	sw.BaseURL = "https://example.com"
+	sw.Feeds = &FeedConfig{Atom: "latest.html", RSS: "none"}
+	assert.NoError(t, ioutil.WriteFile(page, []byte("---\naliases: [new.html, latest]\n---\n"), 0644))
+	err = sw.Run()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "collides with feed")
+	}
+	_, err = os.Stat(filepath.Join(sw.Target, "new.html.html"))
+	assert.True(t, os.IsNotExist(err))
+}
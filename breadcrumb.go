@@ -0,0 +1,59 @@
+package swgen
+
+import (
+	"html/template"
+)
+
+// Ancestors is the directories of the node from the home directory down
+// to its parent
+func (n *Node) Ancestors() []*Node {
+	ancestors := []*Node{}
+	for up := n.Up; up != nil; up = up.Up {
+		ancestors = append([]*Node{up}, ancestors...)
+	}
+	return ancestors
+}
+
+// HasPage reports whether the node has a page worth linking, a directory
+// without an index file or a listing has none
+func (n *Node) HasPage() bool {
+	return !n.Info.IsDir() || n.Index != nil || n.Params.String("listing") != "none"
+}
+
+// BreadcrumbList is the schema.org BreadcrumbList of the node and its
+// ancestors as JSON-LD. The URLs are absolute if BaseURL is set, and left
+// out for the directories without a page.
+func (n *Node) BreadcrumbList() (template.JS, error) {
+	items := []map[string]interface{}{}
+	for i, c := range append(n.Ancestors(), n) {
+		name := c.Title()
+		if c.Up == nil {
+			name = "Home"
+			if n.site != nil {
+				if title := Params(n.site.Params).String("title"); title != "" {
+					name = title
+				}
+			}
+		}
+
+		item := map[string]interface{}{
+			"@type":    "ListItem",
+			"position": i + 1,
+			"name":     name,
+		}
+		if c.HasPage() {
+			url, err := c.PageURL()
+			if err != nil {
+				return "", err
+			}
+			item["item"] = n.absoluteURL(url)
+		}
+		items = append(items, item)
+	}
+
+	return jsonify(map[string]interface{}{
+		"@context":        "https://schema.org",
+		"@type":           "BreadcrumbList",
+		"itemListElement": items,
+	})
+}
@@ -0,0 +1,57 @@
+package swgen
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBreadcrumb(t *testing.T) {
+	source := makeSite(t, map[string]string{
+		"guide/.swdir.yml":            "title: Guide\n",
+		"guide/empty/.swdir.yml":      "listing: none\n",
+		"guide/empty/topic/page.html": "---\ntitle: The Page\n---\n<p>page</p>",
+	})
+	defer os.RemoveAll(source)
+
+	sw := &Swgen{
+		Source:  source,
+		Target:  filepath.Join(source, "testing"),
+		Ignore:  &dummyIgnore{},
+		BaseURL: "https://wiki.example/",
+		Params:  map[string]interface{}{"title": "Wiki"},
+	}
+	var err error
+	sw.Templates, err = sw.LoadTemplates()
+	assert.NoError(t, err)
+	assert.NoError(t, sw.Run())
+
+	tree, err := sw.Scan(source)
+	assert.NoError(t, err)
+	page := tree.Children[0].Children[0].Children[0].Children[0]
+	ancestors := page.Ancestors()
+	if assert.Len(t, ancestors, 4) {
+		assert.Equal(t, tree, ancestors[0])
+		assert.Equal(t, "topic", ancestors[3].Title())
+		assert.False(t, ancestors[2].HasPage())
+	}
+	assert.Empty(t, tree.Ancestors())
+
+	content, err := ioutil.ReadFile(filepath.Join(sw.Target, "guide/empty/topic/page.html.html"))
+	assert.NoError(t, err)
+	html := string(content)
+	assert.Contains(t, html, `<li><a href="/">Wiki</a></li>`)
+	assert.Contains(t, html, `<li><a href="/guide/">Guide</a></li>`)
+	assert.Contains(t, html, `<li>empty</li>`)
+	assert.Contains(t, html, `<li aria-current="page">The Page</li>`)
+	assert.Contains(t, html, `{"@type":"ListItem","item":"https://wiki.example/guide/","name":"Guide","position":2}`)
+	assert.Contains(t, html, `{"@type":"ListItem","name":"empty","position":3}`)
+	assert.Contains(t, html, `"name":"The Page","position":5}]}</script>`)
+
+	content, err = ioutil.ReadFile(filepath.Join(sw.Target, "index.html"))
+	assert.NoError(t, err)
+	assert.NotContains(t, string(content), `class="breadcrumb"`)
+}
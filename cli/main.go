@@ -2,7 +2,6 @@ package main
 
 import (
 	"flag"
-	"html/template"
 	"log"
 	"os"
 	"path/filepath"
@@ -13,8 +12,16 @@ import (
 var (
 	input   = flag.String("input", ".", "input directory")
 	output  = flag.String("output", "./output", "output directory")
+	state   = flag.String("state", "", "build state directory kept out of the output, the output directory with "+swgen.StateSuffix+" by default")
 	root    = flag.String("root", "", "root directory")
 	force   = flag.Bool("force", false, "ignore timestamp")
+	useGit  = flag.Bool("git", false, "use git history for page dates and authors")
+	editURL = flag.String("edit-url", "", "edit link pattern, such as https://git.example/repo/edit/main/{{.Rel}}")
+	source  = flag.Bool("source", false, "publish the raw source next to the rendered page")
+	profile = flag.String("profile", os.Getenv("SWGEN_PROFILE"), "build profile in "+swgen.ConfigFile)
+	drafts  = flag.Bool("drafts", false, "include drafts")
+	relURLs = flag.Bool("relative-urls", false, "link the pages by relative URLs to browse the output from disk")
+	strict  = flag.Bool("strict-templates", false, "report the missing keys in templates as errors")
 	verbose = flag.Bool("verbose", false, "verbose")
 )
 
@@ -29,20 +36,53 @@ func main() {
 	defer f.Close()
 	ignore = swgen.NewBasicIgnore(f)
 
-	templatePattern := filepath.Join(*input, ".template/*.html")
-	tmpl := template.Must(template.ParseGlob(templatePattern))
-	log.Printf("template=%v", tmpl)
 	sw := swgen.Swgen{
-		URLRoot:  *root,
-		Source:   *input,
-		Target:   *output,
-		Force:    *force,
-		Ignore:   ignore,
-		Template: tmpl,
+		URLRoot:       *root,
+		Source:        *input,
+		Target:        *output,
+		Force:         *force,
+		Git:           *useGit,
+		Ignore:        ignore,
+		EditURL:       *editURL,
+		PublishSource: *source,
+		Drafts:        *drafts,
 	}
 
-	err = sw.Run()
+	config, err := swgen.LoadConfig(filepath.Join(*input, swgen.ConfigFile))
 	if err != nil {
 		log.Panic(err)
 	}
+	if err := config.Apply(&sw, *profile); err != nil {
+		log.Panic(err)
+	}
+
+	// the flags given in the command line take precedence over the config
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "root":
+			sw.URLRoot = *root
+		case "output":
+			sw.Target = *output
+		case "state":
+			sw.StateDir = *state
+		case "drafts":
+			sw.Drafts = *drafts
+		case "relative-urls":
+			sw.RelativeURLs = *relURLs
+		case "strict-templates":
+			sw.StrictTemplates = *strict
+		}
+	})
+
+	// the template errors come with the source lines, a stack trace adds
+	// nothing to them
+	sw.Templates, err = sw.LoadTemplates(filepath.Join(*input, swgen.TemplateDir))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	err = sw.Run()
+	if err != nil {
+		log.Fatal(err)
+	}
 }
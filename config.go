@@ -0,0 +1,189 @@
+package swgen
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/gobwas/glob"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// ConfigFile is the name of the site configuration file in the source
+// directory
+const ConfigFile = ".swgen.yml"
+
+// EnvPrefix is the prefix of the environment variables allowed to be read
+// into the template params
+const EnvPrefix = "SWGEN_"
+
+// Profile is the settings of a build, the unset ones are left unchanged.
+// The ignore rules of a profile replace the top level ones, "ignore: []"
+// clears them.
+type Profile struct {
+	URLRoot         *string                  `yaml:"url_root"`
+	BaseURL         *string                  `yaml:"base_url"`
+	Output          *string                  `yaml:"output"`
+	StateDir        *string                  `yaml:"state_dir"`
+	Ignore          []string                 `yaml:"ignore"`
+	Drafts          *bool                    `yaml:"drafts"`
+	Theme           *string                  `yaml:"theme"`
+	RelativeURLs    *bool                    `yaml:"relative_urls"`
+	StrictTemplates *bool                    `yaml:"strict_templates"`
+	Outputs         map[string]*OutputFormat `yaml:"outputs"`
+	Feeds           *FeedConfig              `yaml:"feeds"`
+	Params          map[string]interface{}   `yaml:"params"`
+}
+
+// Config is the site configuration. The top level settings are applied
+// first, then the ones of the active profile.
+type Config struct {
+	Profile `yaml:",inline"`
+
+	// Env is the SWGEN_* environment variables to read into the params,
+	// SWGEN_API_HOST is available as .Site.Params.api_host
+	Env      []string            `yaml:"env"`
+	Profiles map[string]*Profile `yaml:"profiles"`
+}
+
+// LoadConfig loads the site configuration, a missing file results in an
+// empty configuration
+func LoadConfig(path string) (*Config, error) {
+	config := &Config{}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return config, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := yaml.Unmarshal(data, config); err != nil {
+		return nil, fmt.Errorf("parse %s failed: %s", path, err)
+	}
+	return config, nil
+}
+
+// Apply applies the settings and the named profile to swgen, an empty name
+// applies the top level settings only
+func (c *Config) Apply(sw *Swgen, profile string) error {
+	c.Profile.apply(sw)
+	ignore := c.Ignore
+
+	if profile != "" {
+		p, ok := c.Profiles[profile]
+		if !ok {
+			return fmt.Errorf("profile %s is not defined", profile)
+		}
+		p.apply(sw)
+		if p.Ignore != nil {
+			ignore = p.Ignore
+		}
+	}
+	sw.Profile = profile
+
+	if err := applyIgnore(sw, ignore); err != nil {
+		return err
+	}
+
+	env := map[string]interface{}{}
+	for _, name := range c.Env {
+		if !strings.HasPrefix(name, EnvPrefix) {
+			return fmt.Errorf("environment variable %s must start with %s", name, EnvPrefix)
+		}
+		if value, ok := os.LookupEnv(name); ok {
+			env[strings.ToLower(strings.TrimPrefix(name, EnvPrefix))] = value
+		}
+	}
+	if len(env) > 0 {
+		sw.Params = mergeParams(sw.Params, env)
+	}
+	return nil
+}
+
+// apply sets the profile on swgen, the params and outputs are merged into
+// copies so that the maps of the caller are left unchanged
+func (p *Profile) apply(sw *Swgen) {
+	if p.URLRoot != nil {
+		sw.URLRoot = *p.URLRoot
+	}
+	if p.BaseURL != nil {
+		sw.BaseURL = *p.BaseURL
+	}
+	if p.Output != nil {
+		sw.Target = *p.Output
+	}
+	if p.StateDir != nil {
+		sw.StateDir = *p.StateDir
+	}
+	if p.Drafts != nil {
+		sw.Drafts = *p.Drafts
+	}
+	if p.Theme != nil {
+		sw.Theme = *p.Theme
+	}
+	if p.RelativeURLs != nil {
+		sw.RelativeURLs = *p.RelativeURLs
+	}
+	if p.StrictTemplates != nil {
+		sw.StrictTemplates = *p.StrictTemplates
+	}
+	if p.Feeds != nil {
+		sw.Feeds = p.Feeds
+	}
+
+	if len(p.Outputs) > 0 {
+		outputs := map[string]*OutputFormat{}
+		for name, f := range sw.Outputs {
+			outputs[name] = f
+		}
+		for name, f := range p.Outputs {
+			if f == nil {
+				f = &OutputFormat{}
+			}
+			outputs[name] = f
+		}
+		sw.Outputs = outputs
+	}
+
+	if len(p.Params) > 0 {
+		sw.Params = mergeParams(sw.Params, normalize(p.Params).(map[string]interface{}))
+	}
+}
+
+// mergeParams is a copy of the params with the values of override
+func mergeParams(params, override map[string]interface{}) map[string]interface{} {
+	merged := map[string]interface{}{}
+	for k, v := range params {
+		merged[k] = v
+	}
+	for k, v := range override {
+		merged[k] = v
+	}
+	return merged
+}
+
+// applyIgnore adds the ignore rules of the configuration to the ones of
+// swgen, such as the .swignore file
+func applyIgnore(sw *Swgen, ignore []string) error {
+	if len(ignore) == 0 {
+		return nil
+	}
+
+	rules := &BasicIgnore{}
+	for _, rule := range ignore {
+		g, err := glob.Compile(rule, os.PathSeparator)
+		if err != nil {
+			return fmt.Errorf("compile ignore rule %s failed: %s", rule, err)
+		}
+		rules.rules = append(rules.rules, g)
+	}
+
+	if sw.Ignore == nil {
+		sw.Ignore = rules
+	} else {
+		sw.Ignore = AnyIgnore{sw.Ignore, rules}
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package swgen
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfig(t *testing.T) {
+	source := makeSite(t, map[string]string{
+		ConfigFile: `
+url_root: wiki
+ignore: ["drafts/**"]
+params:
+  title: Notes
+  banner: local
+env: [SWGEN_API_HOST, SWGEN_MISSING]
+profiles:
+  public:
+    url_root: ""
+    output: /srv/public
+    state_dir: /var/cache/swgen
+    ignore: ["internal/**"]
+    params:
+      banner: public
+  preview:
+    drafts: true
+    ignore: []
+`,
+	})
+	defer os.RemoveAll(source)
+
+	config, err := LoadConfig(filepath.Join(source, ConfigFile))
+	assert.NoError(t, err)
+
+	os.Setenv("SWGEN_API_HOST", "api.example")
+	defer os.Unsetenv("SWGEN_API_HOST")
+
+	sw := &Swgen{Target: "output", Ignore: &dummyIgnore{}}
+	assert.NoError(t, config.Apply(sw, ""))
+	assert.Equal(t, "wiki", sw.URLRoot)
+	assert.Equal(t, "output", sw.Target)
+	assert.Equal(t, "output"+StateSuffix, sw.stateDir())
+	assert.False(t, sw.Drafts)
+	assert.Equal(t, map[string]interface{}{"title": "Notes", "banner": "local", "api_host": "api.example"}, sw.Params)
+
+	sw = &Swgen{Target: "output", Ignore: &dummyIgnore{}}
+	assert.NoError(t, config.Apply(sw, "public"))
+	assert.Equal(t, "", sw.URLRoot)
+	assert.Equal(t, "/srv/public", sw.Target)
+	assert.Equal(t, "/var/cache/swgen", sw.stateDir())
+	assert.Equal(t, "public", sw.Profile)
+	assert.Equal(t, "public", sw.Params["banner"])
+	assert.True(t, sw.Ignore.Ignore("internal/secret.org"))
+	assert.True(t, sw.Ignore.Ignore("notes.org~"))
+	assert.False(t, sw.Ignore.Ignore("notes.org"))
+	assert.False(t, sw.Ignore.Ignore("drafts/a.org"))
+
+	params := map[string]interface{}{"owner": "me"}
+	sw = &Swgen{Params: params}
+	assert.NoError(t, config.Apply(sw, ""))
+	assert.True(t, sw.Ignore.Ignore("drafts/a.org"))
+	assert.NoError(t, config.Apply(sw, "preview"))
+	assert.True(t, sw.Drafts)
+	assert.Equal(t, "me", sw.Params["owner"])
+	assert.Equal(t, map[string]interface{}{"owner": "me"}, params)
+
+	sw = &Swgen{}
+	assert.NoError(t, config.Apply(sw, "preview"))
+	assert.Nil(t, sw.Ignore)
+
+	assert.Error(t, config.Apply(&Swgen{}, "missing"))
+	assert.Error(t, (&Config{Env: []string{"HOME"}}).Apply(&Swgen{}, ""))
+
+	config, err = LoadConfig(filepath.Join(source, "missing.yml"))
+	assert.NoError(t, err)
+	assert.Equal(t, &Config{}, config)
+}
+
+func TestDrafts(t *testing.T) {
+	source := makeSite(t, map[string]string{
+		ConfigFile:              "params: {}\n",
+		"post.html":             "---\ndraft: true\n---\n",
+		"wip/.swdir.yml":        "draft: true\n",
+		"wip/page.html":         "<p>wip</p>",
+		"wip/image.png":         "png",
+		"published.html":        "<p>published</p>",
+		"notes/draft.html":      "---\ndraft: false\n---\n",
+		"notes/unfinished.html": "---\ndraft: yes\n---\n",
+	})
+	defer os.RemoveAll(source)
+
+	sw := &Swgen{Source: source, Target: filepath.Join(source, "testing"), Ignore: &dummyIgnore{}}
+	tree, err := sw.Scan(source)
+	assert.NoError(t, err)
+	if assert.Len(t, tree.Children, 2) {
+		assert.Equal(t, "notes", tree.Children[0].Info.Name())
+		assert.Len(t, tree.Children[0].Children, 1)
+		assert.Equal(t, "published.html", tree.Children[1].Info.Name())
+	}
+	_, err = os.Stat(filepath.Join(sw.Target, "wip/image.png"))
+	assert.True(t, os.IsNotExist(err))
+	_, err = os.Stat(filepath.Join(sw.Target, ConfigFile))
+	assert.True(t, os.IsNotExist(err))
+
+	sw.Drafts = true
+	tree, err = sw.Scan(source)
+	assert.NoError(t, err)
+	assert.Len(t, tree.Children, 4)
+}
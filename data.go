@@ -0,0 +1,121 @@
+package swgen
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// DataDir is the directory of the data files in the source directory, it
+// is not rendered to the output
+const DataDir = "_data"
+
+// dataDecoders decodes the data file content by its extension
+var dataDecoders = map[string]func([]byte) (interface{}, error){
+	".json": func(b []byte) (interface{}, error) {
+		var v interface{}
+		err := json.Unmarshal(b, &v)
+		return v, err
+	},
+	".yml":  decodeYAML,
+	".yaml": decodeYAML,
+	".toml": func(b []byte) (interface{}, error) {
+		v := map[string]interface{}{}
+		err := toml.Unmarshal(b, &v)
+		return v, err
+	},
+	".csv": decodeCSV,
+}
+
+func decodeYAML(b []byte) (interface{}, error) {
+	var v interface{}
+	if err := yaml.Unmarshal(b, &v); err != nil {
+		return nil, err
+	}
+	return normalize(v), nil
+}
+
+// decodeCSV returns a row list, each row is keyed by the header line
+func decodeCSV(b []byte) (interface{}, error) {
+	records, err := csv.NewReader(strings.NewReader(string(b))).ReadAll()
+	if err != nil || len(records) == 0 {
+		return []interface{}{}, err
+	}
+
+	header := records[0]
+	rows := make([]interface{}, 0, len(records)-1)
+	for _, record := range records[1:] {
+		row := make(map[string]interface{}, len(header))
+		for i, key := range header {
+			if i < len(record) {
+				row[key] = record[i]
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// LoadData loads the JSON, YAML, TOML and CSV files in the directory into a
+// tree keyed by the sub directory and file names without extension, e.g.
+// _data/team/roster.yml is at team.roster. It also returns the latest
+// modification time of the files. A missing directory results in an empty
+// tree.
+func LoadData(dir string) (map[string]interface{}, time.Time, error) {
+	data := map[string]interface{}{}
+	var latest time.Time
+
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if os.IsNotExist(err) && path == dir {
+			return filepath.SkipDir
+		}
+		if err != nil || info.IsDir() {
+			return err
+		}
+
+		ext := filepath.Ext(path)
+		decode, ok := dataDecoders[ext]
+		if !ok {
+			return nil
+		}
+
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		v, err := decode(b)
+		if err != nil {
+			return fmt.Errorf("load data file %s failed: %s", path, err)
+		}
+
+		rel, err := filepath.Rel(dir, strings.TrimSuffix(path, ext))
+		if err != nil {
+			return err
+		}
+		keys := strings.Split(filepath.ToSlash(rel), "/")
+		parent := data
+		for _, key := range keys[:len(keys)-1] {
+			child, ok := parent[key].(map[string]interface{})
+			if !ok {
+				child = map[string]interface{}{}
+				parent[key] = child
+			}
+			parent = child
+		}
+		parent[keys[len(keys)-1]] = v
+
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+		return nil
+	})
+	return data, latest, err
+}
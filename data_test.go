@@ -0,0 +1,89 @@
+package swgen
+
+import (
+	"html/template"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadData(t *testing.T) {
+	source := makeSite(t, map[string]string{
+		"_data/services.json":   `[{"name": "kafka"}, {"name": "zookeeper"}]`,
+		"_data/team/roster.yml": "lead: alice\nmembers: [bob, carol]\n",
+		"_data/site.toml":       "title = \"Wiki\"\n[owner]\nname = \"ops\"\n",
+		"_data/hosts.csv":       "host,port\na,1\nb,2\n",
+		"_data/notes.txt":       "ignored",
+	})
+	defer os.RemoveAll(source)
+
+	data, latest, err := LoadData(filepath.Join(source, DataDir))
+	assert.NoError(t, err)
+	assert.False(t, latest.IsZero())
+	assert.Len(t, data, 4)
+	assert.Equal(t, []interface{}{map[string]interface{}{"name": "kafka"}, map[string]interface{}{"name": "zookeeper"}}, data["services"])
+	assert.Equal(t, map[string]interface{}{
+		"roster": map[string]interface{}{"lead": "alice", "members": []interface{}{"bob", "carol"}},
+	}, data["team"])
+	assert.Equal(t, "Wiki", data["site"].(map[string]interface{})["title"])
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"host": "a", "port": "1"},
+		map[string]interface{}{"host": "b", "port": "2"},
+	}, data["hosts"])
+
+	data, _, err = LoadData(filepath.Join(source, "missing"))
+	assert.NoError(t, err)
+	assert.Empty(t, data)
+}
+
+func TestDataUsers(t *testing.T) {
+	source := makeSite(t, map[string]string{
+		"_data/team.yml": "lead: alice\n",
+		"team.html":      "---\nlayout: team\n---\n",
+		"other.html":     "<p>other</p>",
+	})
+	defer os.RemoveAll(source)
+
+	tmpl := template.Must(template.New("page").Parse(`{{.Page}}`))
+	template.Must(tmpl.New("team").Parse(`lead: {{.Site.Data.team.lead}}`))
+	sw := Swgen{
+		Source:   source,
+		Target:   filepath.Join(source, "testing"),
+		Ignore:   &dummyIgnore{},
+		Template: tmpl,
+	}
+	assert.NoError(t, sw.Run())
+
+	read := func(name string) string {
+		content, err := ioutil.ReadFile(filepath.Join(sw.Target, name))
+		assert.NoError(t, err)
+		return string(content)
+	}
+	assert.Equal(t, "lead: alice", read("team.html.html"))
+	assert.Equal(t, "team.html\n", readState(&sw, dataUsersFile))
+	for _, name := range []string{DataDir, dataUsersFile} {
+		_, err := os.Stat(filepath.Join(sw.Target, name))
+		assert.True(t, os.IsNotExist(err), name)
+	}
+
+	// pages are newer than their sources, only the data user is rendered
+	// again when the data file changes
+	past := time.Now().Add(-time.Hour)
+	for _, name := range []string{"team.html", "other.html"} {
+		assert.NoError(t, os.Chtimes(filepath.Join(source, name), past, past))
+	}
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(source, "other.html"), []byte("<p>changed</p>"), 0644))
+	assert.NoError(t, os.Chtimes(filepath.Join(source, "other.html"), past, past))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(source, DataDir, "team.yml"), []byte("lead: bob\n"), 0644))
+	future := time.Now().Add(time.Hour)
+	assert.NoError(t, os.Chtimes(filepath.Join(source, DataDir, "team.yml"), future, future))
+
+	assert.NoError(t, sw.Run())
+	assert.Equal(t, "lead: bob", read("team.html.html"))
+	assert.Equal(t, "<p>other</p>", read("other.html.html"))
+	assert.Equal(t, "team.html\n", readState(&sw, dataUsersFile))
+}
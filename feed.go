@@ -0,0 +1,443 @@
+package swgen
+
+import (
+	"encoding/xml"
+	"fmt"
+	"html/template"
+	"io/ioutil"
+	"log"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// DefaultFeedLimit is the number of entries of a feed without a limit
+const DefaultFeedLimit = 20
+
+// FeedConfig is the settings of the Atom and RSS feeds. The site feed has
+// the latest pages of the whole site, and each of the sections, such as
+// "blog" or "notes/go", gets its own feed in its output directory.
+type FeedConfig struct {
+	// Limit is the number of entries, DefaultFeedLimit if it is 0
+	Limit int `yaml:"limit"`
+	// FullContent puts the rendered pages rather than the summaries into
+	// the entries
+	FullContent bool     `yaml:"full_content"`
+	Sections    []string `yaml:"sections"`
+	// Atom and RSS are the file names of the feeds, atom.xml and rss.xml
+	// by default, "none" turns the format off
+	Atom string `yaml:"atom"`
+	RSS  string `yaml:"rss"`
+}
+
+// feed is a feed to write, the pages are the ones under dir
+type feed struct {
+	title  string
+	author string
+	dir    *Node
+	pages  Pages
+}
+
+func (c *FeedConfig) limit() int {
+	if c.Limit <= 0 {
+		return DefaultFeedLimit
+	}
+	return c.Limit
+}
+
+// files is the output file name of each format
+func (c *FeedConfig) files() map[string]string {
+	files := map[string]string{}
+	for format, name := range map[string]string{"atom": c.Atom, "rss": c.RSS} {
+		switch name {
+		case "none":
+			continue
+		case "":
+			name = format + ".xml"
+		}
+		files[format] = name
+	}
+	return files
+}
+
+// feedTitle is the site title, followed by the section title for the
+// feed of a section
+func (sw *Swgen) feedTitle(dir *Node) string {
+	title := Params(sw.Params).String("title")
+	root := dir
+	for root.Up != nil {
+		root = root.Up
+	}
+	if title == "" {
+		title = root.Title()
+	}
+	if dir != root {
+		title += " - " + dir.Title()
+	}
+	return title
+}
+
+// feedAuthor is the "author" param of the site, or the site title, for
+// the entries without their own authors
+func (sw *Swgen) feedAuthor(tree *Node) string {
+	if author := Params(sw.Params).String("author"); author != "" {
+		return author
+	}
+	return sw.feedTitle(tree)
+}
+
+// isSection reports whether the node is the directory of the section
+func (n *Node) isSection(section string) bool {
+	rel := filepath.ToSlash(n.MustGetRelPath(n.path))
+	return n.Info.IsDir() && rel == strings.Trim(filepath.ToSlash(section), "/")
+}
+
+// feeds is the site feed and the feeds of the configured sections
+func (sw *Swgen) feeds(tree *Node) ([]*feed, error) {
+	if sw.BaseURL == "" {
+		return nil, fmt.Errorf("feeds need the base URL")
+	}
+
+	feeds := []*feed{{title: sw.feedTitle(tree), author: sw.feedAuthor(tree), dir: tree}}
+	for _, section := range sw.Feeds.Sections {
+		var dir *Node
+		tree.Walk(func(n *Node) error {
+			if n.isSection(section) {
+				dir = n
+			}
+			return nil
+		})
+		if dir == nil {
+			return nil, fmt.Errorf("feed section %s is not found", section)
+		}
+		feeds = append(feeds, &feed{title: sw.feedTitle(dir), author: sw.feedAuthor(tree), dir: dir})
+	}
+
+	for _, f := range feeds {
+		pages := f.dir.RegularPages().filter(func(n *Node) bool {
+			_, ok := n.Params["feed"]
+			return !ok || n.Params.Bool("feed")
+		})
+		sorted, err := pages.Sort("-date")
+		if err != nil {
+			return nil, err
+		}
+		f.pages = sorted.Limit(sw.Feeds.limit())
+	}
+	return feeds, nil
+}
+
+// renderFeeds writes the Atom and RSS feeds, the links in them are made
+// absolute by BaseURL. A page with "feed: false" is left out.
+func (sw *Swgen) renderFeeds(tree *Node, m *Metadata) error {
+	if sw.Feeds == nil {
+		return nil
+	}
+
+	feeds, err := sw.feeds(tree)
+	if err != nil {
+		return err
+	}
+
+	for _, f := range feeds {
+		entries := []*feedEntry{}
+		for _, n := range f.pages {
+			entry, err := sw.feedEntry(n, m)
+			if err != nil {
+				return err
+			}
+			entries = append(entries, entry)
+		}
+
+		for format, name := range sw.Feeds.files() {
+			dest := sw.feedFile(f.dir, name)
+			self, err := sw.fileURL(dest)
+			if err != nil {
+				return err
+			}
+			home, err := f.dir.PageURL()
+			if err != nil {
+				return err
+			}
+
+			var doc interface{}
+			if format == "atom" {
+				doc = atomFeed(f, sw.absoluteURL(home), sw.absoluteURL(self), entries)
+			} else {
+				doc = rssFeed(f, sw.absoluteURL(home), entries)
+			}
+
+			data, err := xml.MarshalIndent(doc, "", "  ")
+			if err != nil {
+				return err
+			}
+			log.Printf("write feed %s", dest)
+			if err := ioutil.WriteFile(dest, append([]byte(xml.Header), data...), 0644); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// feedFile is the output file of the feed of the directory
+func (sw *Swgen) feedFile(dir *Node, name string) string {
+	return filepath.Join(sw.MustGetTargetPath(dir.path), name)
+}
+
+// feedFiles is the output files of all the feeds, it is empty if the feeds
+// are not configured
+func (sw *Swgen) feedFiles(tree *Node) ([]string, error) {
+	if sw.Feeds == nil {
+		return nil, nil
+	}
+	feeds, err := sw.feeds(tree)
+	if err != nil {
+		return nil, err
+	}
+
+	files := []string{}
+	for _, f := range feeds {
+		for _, name := range sw.Feeds.files() {
+			files = append(files, sw.feedFile(f.dir, name))
+		}
+	}
+	return files, nil
+}
+
+// absoluteURL prefixes the site absolute URL with the base URL
+func (sw *Swgen) absoluteURL(url string) string {
+	return strings.TrimSuffix(sw.BaseURL, "/") + url
+}
+
+type feedEntry struct {
+	title     string
+	id        string
+	url       string
+	author    string
+	published time.Time
+	updated   time.Time
+	summary   string
+	content   template.HTML
+}
+
+// feedEntry is the entry of the page, its id is the "id" param or the
+// absolute URL. The links of the full content are made absolute, the ones
+// relative to the page included.
+func (sw *Swgen) feedEntry(n *Node, m *Metadata) (*feedEntry, error) {
+	url, err := n.PageURL()
+	if err != nil {
+		return nil, err
+	}
+	summary, err := n.Summary()
+	if err != nil {
+		return nil, err
+	}
+
+	entry := &feedEntry{
+		title:     n.Title(),
+		id:        n.Params.String("id"),
+		url:       sw.absoluteURL(url),
+		author:    n.Params.String("author"),
+		published: n.Date(),
+		updated:   n.ModTime(),
+		summary:   summary,
+	}
+	if entry.id == "" {
+		entry.id = entry.url
+	}
+	if entry.author == "" && n.Git != nil {
+		entry.author = n.Git.Author
+	}
+	if entry.updated.Before(entry.published) {
+		entry.updated = entry.published
+	}
+
+	if sw.Feeds.FullContent {
+		html, err := n.renderContent(m)
+		if err != nil {
+			return nil, err
+		}
+		html, _, _ = n.Toc(html)
+		// the relative links point next to the source, the fragments to
+		// the page itself
+		source, err := n.sourceURL()
+		if err != nil {
+			return nil, err
+		}
+		resolved := resolveURLs(resolveLinks([]byte(html), source, false), url)
+		entry.content = template.HTML(sw.absolutize(resolved))
+	}
+	return entry, nil
+}
+
+// FeedLinks is the <link rel="alternate"> tags of the site feeds and the
+// feeds of the sections the node is in, for the head of the layouts
+func (n *Node) FeedLinks() (template.HTML, error) {
+	if n.Feeds == nil {
+		return "", nil
+	}
+
+	root := n
+	for root.Up != nil {
+		root = root.Up
+	}
+	dirs := []*Node{root}
+	for _, section := range n.Feeds.Sections {
+		for up := n; up != nil; up = up.Up {
+			if up.isSection(section) {
+				dirs = append(dirs, up)
+			}
+		}
+	}
+
+	types := map[string]string{"atom": "application/atom+xml", "rss": "application/rss+xml"}
+	sb := &strings.Builder{}
+	for _, dir := range dirs {
+		for _, format := range []string{"atom", "rss"} {
+			name, ok := n.Feeds.files()[format]
+			if !ok {
+				continue
+			}
+			url, err := n.fileURL(filepath.Join(n.MustGetTargetPath(dir.path), name))
+			if err != nil {
+				return "", err
+			}
+			fmt.Fprintf(sb, `<link rel="alternate" type="%s" title="%s" href="%s" />`+"\n",
+				types[format], template.HTMLEscapeString(n.feedTitle(dir)), template.HTMLEscapeString(url))
+		}
+	}
+	return template.HTML(sb.String()), nil
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type atomText struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+type atomPerson struct {
+	Name string `xml:"name"`
+}
+
+type atomEntry struct {
+	Title     string      `xml:"title"`
+	ID        string      `xml:"id"`
+	Link      atomLink    `xml:"link"`
+	Published string      `xml:"published"`
+	Updated   string      `xml:"updated"`
+	Author    *atomPerson `xml:"author,omitempty"`
+	Summary   *atomText   `xml:"summary,omitempty"`
+	Content   *atomText   `xml:"content,omitempty"`
+}
+
+type atom struct {
+	XMLName xml.Name     `xml:"http://www.w3.org/2005/Atom feed"`
+	Title   string       `xml:"title"`
+	ID      string       `xml:"id"`
+	Updated string       `xml:"updated"`
+	Author  atomPerson   `xml:"author"`
+	Links   []atomLink   `xml:"link"`
+	Entries []*atomEntry `xml:"entry"`
+}
+
+// atomFeed is the Atom document of the feed, it is updated when the latest
+// entry is, or the directory of the feed without entries so that the same
+// tree builds the same feed
+func atomFeed(f *feed, home, self string, entries []*feedEntry) *atom {
+	doc := &atom{
+		Title:  f.title,
+		ID:     home,
+		Author: atomPerson{Name: f.author},
+		Links:  []atomLink{{Href: home}, {Href: self, Rel: "self", Type: "application/atom+xml"}},
+	}
+
+	updated := time.Time{}
+	for _, e := range entries {
+		entry := &atomEntry{
+			Title:     e.title,
+			ID:        e.id,
+			Link:      atomLink{Href: e.url},
+			Published: e.published.Format(time.RFC3339),
+			Updated:   e.updated.Format(time.RFC3339),
+		}
+		if e.author != "" {
+			entry.Author = &atomPerson{Name: e.author}
+		}
+		if e.content != "" {
+			entry.Content = &atomText{Type: "html", Body: string(e.content)}
+		} else if e.summary != "" {
+			entry.Summary = &atomText{Type: "text", Body: e.summary}
+		}
+		if e.updated.After(updated) {
+			updated = e.updated
+		}
+		doc.Entries = append(doc.Entries, entry)
+	}
+	if updated.IsZero() {
+		updated = f.dir.ModTime()
+	}
+	doc.Updated = updated.Format(time.RFC3339)
+	return doc
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+type rssItem struct {
+	Title       string  `xml:"title"`
+	Link        string  `xml:"link"`
+	GUID        rssGUID `xml:"guid"`
+	PubDate     string  `xml:"pubDate"`
+	Description string  `xml:"description,omitempty"`
+}
+
+type rssChannel struct {
+	Title         string     `xml:"title"`
+	Link          string     `xml:"link"`
+	Description   string     `xml:"description"`
+	LastBuildDate string     `xml:"lastBuildDate,omitempty"`
+	Items         []*rssItem `xml:"item"`
+}
+
+type rss struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+func rssFeed(f *feed, home string, entries []*feedEntry) *rss {
+	doc := &rss{
+		Version: "2.0",
+		Channel: rssChannel{Title: f.title, Link: home, Description: f.title},
+	}
+
+	updated := time.Time{}
+	for _, e := range entries {
+		item := &rssItem{
+			Title:       e.title,
+			Link:        e.url,
+			GUID:        rssGUID{IsPermaLink: e.id == e.url, Value: e.id},
+			PubDate:     e.published.Format(time.RFC1123Z),
+			Description: e.summary,
+		}
+		if e.content != "" {
+			item.Description = string(e.content)
+		}
+		if e.updated.After(updated) {
+			updated = e.updated
+		}
+		doc.Channel.Items = append(doc.Channel.Items, item)
+	}
+	if !updated.IsZero() {
+		doc.Channel.LastBuildDate = updated.Format(time.RFC1123Z)
+	}
+	return doc
+}
@@ -0,0 +1,161 @@
+package swgen
+
+import (
+	"encoding/xml"
+	"html/template"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFeeds(t *testing.T) {
+	source := makeSite(t, map[string]string{
+		"blog/.swdir.yml":  "title: Blog\n",
+		"blog/old.html":    "---\ndate: 2018-01-01\nauthor: Ann\n---\n<p>old</p>",
+		"blog/new.html":    "---\ndate: 2019-06-01\nid: urn:uuid:new\nsummary: The new one\n---\n<p><a href=\"/wiki/blog/old.html.html\">old</a></p>",
+		"blog/mid.html":    "---\ndate: 2019-01-01\n---\n<p><img src=\"mid.png\"> <a href=\"../about.html.html\">about</a></p>",
+		"blog/secret.html": "---\ndate: 2020-01-01\nfeed: false\n---\n",
+		"about.html":       "---\ndate: 2017-01-01\n---\n<p>about</p>",
+		"drafts/a.html":    "---\nfeed: false\n---\n",
+	})
+	defer os.RemoveAll(source)
+
+	sw := &Swgen{
+		Source:  source,
+		Target:  filepath.Join(source, "testing"),
+		Ignore:  &dummyIgnore{},
+		BaseURL: "https://wiki.example/",
+		URLRoot: "wiki",
+		Params:  map[string]interface{}{"title": "Wiki"},
+		Feeds:   &FeedConfig{Limit: 3, Sections: []string{"blog", "drafts"}, FullContent: true},
+	}
+	var err error
+	sw.Templates, err = sw.LoadTemplates()
+	assert.NoError(t, err)
+	assert.NoError(t, sw.Run())
+
+	read := func(name string) []byte {
+		content, err := ioutil.ReadFile(filepath.Join(sw.Target, name))
+		assert.NoError(t, err, name)
+		return content
+	}
+
+	feed := &atom{}
+	assert.NoError(t, xml.Unmarshal(read("atom.xml"), feed))
+	assert.Equal(t, "Wiki", feed.Title)
+	assert.Equal(t, "https://wiki.example/wiki/", feed.ID)
+	assert.Equal(t, "https://wiki.example/wiki/atom.xml", feed.Links[1].Href)
+	assert.Equal(t, "Wiki", feed.Author.Name)
+	if assert.Len(t, feed.Entries, 3) {
+		entry := feed.Entries[0]
+		assert.Equal(t, "new", entry.Title)
+		assert.Equal(t, "urn:uuid:new", entry.ID)
+		assert.Equal(t, "https://wiki.example/wiki/blog/new.html.html", entry.Link.Href)
+		assert.Equal(t, "2019-06-01T00:00:00Z", entry.Published)
+		assert.Contains(t, entry.Content.Body, `<a href="https://wiki.example/wiki/blog/old.html.html">old</a>`)
+		assert.Equal(t, "mid", feed.Entries[1].Title)
+		assert.Equal(t, "https://wiki.example/wiki/blog/mid.html.html", feed.Entries[1].ID)
+		assert.Contains(t, feed.Entries[1].Content.Body, `<img src="https://wiki.example/wiki/blog/mid.png">`)
+		assert.Contains(t, feed.Entries[1].Content.Body, `<a href="https://wiki.example/wiki/about.html.html">`)
+		assert.Equal(t, "Ann", feed.Entries[2].Author.Name)
+	}
+
+	section := &rss{}
+	assert.NoError(t, xml.Unmarshal(read("blog/rss.xml"), section))
+	assert.Equal(t, "Wiki - Blog", section.Channel.Title)
+	if assert.Len(t, section.Channel.Items, 3) {
+		item := section.Channel.Items[0]
+		assert.Equal(t, "urn:uuid:new", item.GUID.Value)
+		assert.False(t, item.GUID.IsPermaLink)
+		assert.Equal(t, "Sat, 01 Jun 2019 00:00:00 +0000", item.PubDate)
+		assert.True(t, section.Channel.Items[1].GUID.IsPermaLink)
+	}
+
+	// a feed without entries is dated by its directory
+	empty := &atom{}
+	assert.NoError(t, xml.Unmarshal(read("drafts/atom.xml"), empty))
+	assert.Empty(t, empty.Entries)
+	info, err := os.Stat(filepath.Join(source, "drafts"))
+	assert.NoError(t, err)
+	assert.Equal(t, info.ModTime().Format(time.RFC3339), empty.Updated)
+
+	page := string(read("blog/old.html.html"))
+	assert.Contains(t, page, `<link rel="alternate" type="application/atom+xml" title="Wiki" href="/wiki/atom.xml" />`)
+	assert.Contains(t, page, `<link rel="alternate" type="application/rss+xml" title="Wiki - Blog" href="/wiki/blog/rss.xml" />`)
+	assert.NotContains(t, string(read("about.html.html")), "blog/rss.xml")
+
+	sw.Feeds = &FeedConfig{RSS: "none", Sections: []string{"missing"}}
+	err = sw.Run()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "feed section missing is not found")
+	}
+
+	sw.BaseURL = ""
+	err = sw.Run()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "feeds need the base URL")
+	}
+}
+
+func TestFeedContent(t *testing.T) {
+	// kramdown stands in by a renderer counting its runs
+	runs := 0
+	markdown := RenderFns[".md"]
+	RenderFns[".md"] = func(n *Node, m *Metadata) (template.HTML, error) {
+		runs++
+		if n.Info.Name() == "c.md" {
+			return `<p><img src="pic.png"><a href="#top">top</a></p>`, nil
+		}
+		return "<h1>Intro</h1>\n<p>Some <em>text</em>.</p>\n", nil
+	}
+	defer func() { RenderFns[".md"] = markdown }()
+
+	source := makeSite(t, map[string]string{
+		"a.md": "---\ndate: 2019-01-01\n---\n# Intro\n\nSome *text*.\n",
+		"b.md": "---\ndate: 2019-02-01\n---\n# Intro\n\nSome *text*.\n",
+		// the permalink moves the page away from its attachment
+		"blog/.swdir.yml": "permalink: /:year/:slug/\n",
+		"blog/c.md":       "---\ndate: 2018-01-01\n---\n![](pic.png)\n",
+		"blog/pic.png":    "png",
+	})
+	defer os.RemoveAll(source)
+
+	sw := &Swgen{
+		Source:   source,
+		Target:   filepath.Join(source, "testing"),
+		Ignore:   &dummyIgnore{},
+		BaseURL:  "https://wiki.example",
+		Template: template.Must(template.New("page").Parse(`{{.Page}}`)),
+		Feeds:    &FeedConfig{RSS: "none"},
+	}
+	assert.NoError(t, sw.Run())
+	assert.Equal(t, 3, runs)
+
+	feed := &atom{}
+	content, err := ioutil.ReadFile(filepath.Join(sw.Target, "atom.xml"))
+	assert.NoError(t, err)
+	assert.NoError(t, xml.Unmarshal(content, feed))
+	if assert.Len(t, feed.Entries, 3) {
+		assert.Equal(t, "Intro Some text.", feed.Entries[0].Summary.Body)
+	}
+
+	// the full content of the skipped pages comes from the cache
+	sw.Feeds.FullContent = true
+	assert.NoError(t, sw.Run())
+	assert.Equal(t, 3, runs)
+	content, err = ioutil.ReadFile(filepath.Join(sw.Target, "atom.xml"))
+	assert.NoError(t, err)
+	assert.Contains(t, string(content), "&lt;em&gt;text&lt;/em&gt;")
+
+	feed = &atom{}
+	assert.NoError(t, xml.Unmarshal(content, feed))
+	if assert.Len(t, feed.Entries, 3) {
+		assert.Equal(t, "https://wiki.example/2018/c/", feed.Entries[2].Link.Href)
+		assert.Equal(t, `<p><img src="https://wiki.example/blog/pic.png">`+
+			`<a href="https://wiki.example/2018/c/#top">top</a></p>`, feed.Entries[2].Content.Body)
+	}
+}
@@ -0,0 +1,365 @@
+package swgen
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"path"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// FuncMap is the functions registered for every template parse, the ones
+// in Swgen.Funcs are added last and replace the builtin ones of the same
+// name.
+//
+//	dateFormat LAYOUT TIME      format a time or date string with the Go layout
+//	relURL PATH                 prefix the site relative path with the URL root
+//	absURL PATH                 prefix the site relative path with the base URL
+//	themeURL PATH               the URL of the theme's static asset
+//	markdownify TEXT            render markdown text to HTML
+//	plainify HTML               strip the HTML tags
+//	truncate LENGTH TEXT        cut the text to the length in characters, adding "…"
+//	slugify TEXT                lowercase the text and join the words with "-"
+//	sortBy KEY [ORDER] LIST     sort by a field, method, map key or dotted path
+//	                            such as "Params.weight", ORDER is "asc" or "desc"
+//	where LIST KEY [OP] VALUE   filter by comparing the key, OP is one of
+//	                            "=", "!=", "<", "<=", ">", ">=", "in" and
+//	                            "has" for a list or comma separated key
+//	first N LIST                the first N items
+//	default DEFAULT VALUE       the value, or the default if the value is empty
+//	jsonify VALUE               encode the value as JSON
+//	fileSize BYTES              format a byte count such as "1.5 KB"
+func (sw *Swgen) FuncMap() template.FuncMap {
+	funcs := template.FuncMap{
+		"dateFormat":  dateFormat,
+		"relURL":      sw.relURL,
+		"absURL":      sw.absURL,
+		"themeURL":    sw.themeURL,
+		"markdownify": sw.markdownify,
+		"plainify":    plainify,
+		"truncate":    truncate,
+		"slugify":     Slugify,
+		"sortBy":      sortBy,
+		"where":       where,
+		"first":       first,
+		"default":     defaultValue,
+		"jsonify":     jsonify,
+		"fileSize":    fileSize,
+	}
+
+	for name, fn := range sw.Funcs {
+		funcs[name] = fn
+	}
+	return funcs
+}
+
+// ParseTemplates parses the template files matching the pattern with the
+// FuncMap, the first file names the returned template
+func (sw *Swgen) ParseTemplates(pattern string) (*template.Template, error) {
+	files, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no template matches %s", pattern)
+	}
+
+	return template.New(filepath.Base(files[0])).Funcs(sw.FuncMap()).ParseFiles(files...)
+}
+
+func dateFormat(layout string, v interface{}) (string, error) {
+	switch v := v.(type) {
+	case time.Time:
+		return v.Format(layout), nil
+	case string:
+		t, ok := Params{"date": v}.Time("date")
+		if !ok {
+			return "", fmt.Errorf("unknown date format %s", v)
+		}
+		return t.Format(layout), nil
+	}
+	return "", fmt.Errorf("can not format %v as date", v)
+}
+
+func (sw *Swgen) relURL(p string) string {
+	url := path.Join("/", sw.URLRoot, p)
+	if strings.HasSuffix(p, "/") && url != "/" {
+		url += "/"
+	}
+	return url
+}
+
+func (sw *Swgen) absURL(p string) string {
+	return strings.TrimSuffix(sw.BaseURL, "/") + sw.relURL(p)
+}
+
+func (sw *Swgen) markdownify(text string) (template.HTML, error) {
+	n := &Node{
+		Swgen: sw,
+		path:  filepath.Join(sw.Source, "markdownify.md"),
+		body:  []byte(text),
+	}
+	return RenderFns[".md"](n, nil)
+}
+
+var htmlTag = regexp.MustCompile(`<[^>]*>`)
+
+func plainify(v interface{}) string {
+	return htmlTag.ReplaceAllString(fmt.Sprint(v), "")
+}
+
+func truncate(length int, v interface{}) string {
+	text := fmt.Sprint(v)
+	if utf8.RuneCountInString(text) <= length {
+		return text
+	}
+	return strings.TrimSpace(string([]rune(text)[:length])) + "…"
+}
+
+func defaultValue(def, v interface{}) interface{} {
+	if isEmpty(v) {
+		return def
+	}
+	return v
+}
+
+func jsonify(v interface{}) (template.JS, error) {
+	b, err := json.Marshal(v)
+	return template.JS(b), err
+}
+
+func first(n int, list interface{}) (interface{}, error) {
+	v := reflect.ValueOf(list)
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return nil, fmt.Errorf("can not take items from %T", list)
+	}
+	if n > v.Len() {
+		n = v.Len()
+	}
+	return v.Slice(0, n).Interface(), nil
+}
+
+// sortBy returns a sorted copy of the list, it is called as
+// sortBy KEY LIST or sortBy KEY ORDER LIST
+func sortBy(key string, args ...interface{}) (interface{}, error) {
+	if len(args) == 0 || len(args) > 2 {
+		return nil, fmt.Errorf("sortBy takes a key, an optional order and a list")
+	}
+	order := "asc"
+	if len(args) == 2 {
+		order = fmt.Sprint(args[0])
+	}
+	if order != "asc" && order != "desc" {
+		return nil, fmt.Errorf("unknown sort order %s", order)
+	}
+
+	v := reflect.ValueOf(args[len(args)-1])
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return nil, fmt.Errorf("can not sort %T", args[len(args)-1])
+	}
+
+	sorted := reflect.MakeSlice(reflect.SliceOf(v.Type().Elem()), v.Len(), v.Len())
+	reflect.Copy(sorted, v)
+	sort.SliceStable(sorted.Interface(), func(i, j int) bool {
+		c := compareValues(valueOf(sorted.Index(i).Interface(), key), valueOf(sorted.Index(j).Interface(), key))
+		if order == "desc" {
+			return c > 0
+		}
+		return c < 0
+	})
+	return sorted.Interface(), nil
+}
+
+// where returns the items of the list matching the condition, it is
+// called as where LIST KEY VALUE or where LIST KEY OP VALUE
+func where(list interface{}, key string, args ...interface{}) (interface{}, error) {
+	op, match := "=", interface{}(nil)
+	switch len(args) {
+	case 1:
+		match = args[0]
+	case 2:
+		op, match = fmt.Sprint(args[0]), args[1]
+	default:
+		return nil, fmt.Errorf("where takes a list, a key, an optional operator and a value")
+	}
+
+	v := reflect.ValueOf(list)
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return nil, fmt.Errorf("can not filter %T", list)
+	}
+
+	result := reflect.MakeSlice(reflect.SliceOf(v.Type().Elem()), 0, v.Len())
+	for i := 0; i < v.Len(); i++ {
+		item := v.Index(i)
+		ok, err := matchValue(valueOf(item.Interface(), key), op, match)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			result = reflect.Append(result, item)
+		}
+	}
+	return result.Interface(), nil
+}
+
+func matchValue(v interface{}, op string, match interface{}) (bool, error) {
+	switch op {
+	case "=", "==", "eq":
+		return compareValues(v, match) == 0, nil
+	case "!=", "ne":
+		return compareValues(v, match) != 0, nil
+	case "<", "lt":
+		return compareValues(v, match) < 0, nil
+	case "<=", "le":
+		return compareValues(v, match) <= 0, nil
+	case ">", "gt":
+		return compareValues(v, match) > 0, nil
+	case ">=", "ge":
+		return compareValues(v, match) >= 0, nil
+	case "has":
+		for _, item := range toStrings(v) {
+			if compareValues(item, fmt.Sprint(match)) == 0 {
+				return true, nil
+			}
+		}
+		return false, nil
+	case "in":
+		list := reflect.ValueOf(match)
+		if list.Kind() != reflect.Slice && list.Kind() != reflect.Array {
+			return false, fmt.Errorf("the value of in must be a list")
+		}
+		for i := 0; i < list.Len(); i++ {
+			if compareValues(v, list.Index(i).Interface()) == 0 {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+	return false, fmt.Errorf("unknown operator %s", op)
+}
+
+// valueOf resolves the dotted key against the item, each part is a map
+// key, a method without arguments or a field
+func valueOf(item interface{}, key string) interface{} {
+	for _, part := range strings.Split(key, ".") {
+		if part == "" {
+			continue
+		}
+
+		v := reflect.ValueOf(item)
+		if !v.IsValid() {
+			return nil
+		}
+
+		if m := v.MethodByName(part); m.IsValid() && m.Type().NumIn() == 0 && m.Type().NumOut() > 0 {
+			item = m.Call(nil)[0].Interface()
+			continue
+		}
+
+		for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+			if v.IsNil() {
+				return nil
+			}
+			v = v.Elem()
+		}
+
+		switch v.Kind() {
+		case reflect.Map:
+			if v.Type().Key().Kind() != reflect.String {
+				return nil
+			}
+			value := v.MapIndex(reflect.ValueOf(part).Convert(v.Type().Key()))
+			if !value.IsValid() {
+				return nil
+			}
+			item = value.Interface()
+		case reflect.Struct:
+			field := v.FieldByName(part)
+			if !field.IsValid() || !field.CanInterface() {
+				return nil
+			}
+			item = field.Interface()
+		default:
+			return nil
+		}
+	}
+	return item
+}
+
+// compareValues compares numbers, times and strings, the other values are
+// compared by their string forms. A nil value goes first.
+func compareValues(a, b interface{}) int {
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return -1
+	case b == nil:
+		return 1
+	}
+
+	if fa, ok := toFloat(a); ok {
+		if fb, ok := toFloat(b); ok {
+			switch {
+			case fa < fb:
+				return -1
+			case fa > fb:
+				return 1
+			}
+			return 0
+		}
+	}
+
+	if ta, ok := a.(time.Time); ok {
+		if tb, ok := b.(time.Time); ok {
+			switch {
+			case ta.Before(tb):
+				return -1
+			case ta.After(tb):
+				return 1
+			}
+			return 0
+		}
+	}
+
+	return strings.Compare(fmt.Sprint(a), fmt.Sprint(b))
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), true
+	}
+	return 0, false
+}
+
+func isEmpty(v interface{}) bool {
+	rv := reflect.ValueOf(v)
+	if !rv.IsValid() {
+		return true
+	}
+
+	switch rv.Kind() {
+	case reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+		return rv.Len() == 0
+	case reflect.Ptr, reflect.Interface:
+		return rv.IsNil()
+	case reflect.Bool:
+		return !rv.Bool()
+	}
+	if t, ok := v.(time.Time); ok {
+		return t.IsZero()
+	}
+	return reflect.DeepEqual(v, reflect.Zero(rv.Type()).Interface())
+}
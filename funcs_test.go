@@ -0,0 +1,92 @@
+package swgen
+
+import (
+	"html/template"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFuncMap(t *testing.T) {
+	sw := &Swgen{
+		URLRoot: "wiki",
+		BaseURL: "https://example.com/",
+		Funcs: template.FuncMap{
+			"shout":    strings.ToUpper,
+			"truncate": func(n int, s string) string { return "custom" },
+		},
+	}
+
+	items := []map[string]interface{}{
+		{"name": "b", "weight": 2, "tags": "x"},
+		{"name": "a", "weight": 3},
+		{"name": "c", "weight": 1, "tags": "y"},
+	}
+	date := time.Date(2019, 7, 1, 0, 0, 0, 0, time.UTC)
+	cases := []struct {
+		tmpl   string
+		expect string
+	}{
+		{`{{dateFormat "Jan 2, 2006" .date}}`, "Jul 1, 2019"},
+		{`{{dateFormat "2006" "2019-07-01"}}`, "2019"},
+		{`{{relURL "a/b"}} {{relURL "dir/"}} {{relURL "/"}}`, "/wiki/a/b /wiki/dir/ /wiki/"},
+		{`{{absURL "a/b"}}`, "https://example.com/wiki/a/b"},
+		{`{{plainify "<p>Hello <b>world</b></p>"}}`, "Hello world"},
+		{`{{slugify "Hello World"}}`, "hello-world"},
+		{`{{range sortBy "weight" .items}}{{.name}}{{end}}`, "cba"},
+		{`{{range sortBy "name" "desc" .items}}{{.name}}{{end}}`, "cba"},
+		{`{{range where .items "weight" ">=" 2}}{{.name}}{{end}}`, "ba"},
+		{`{{range where .items "tags" "y"}}{{.name}}{{end}}`, "c"},
+		{`{{range where .items "name" "in" .names}}{{.name}}{{end}}`, "ba"},
+		{`{{range first 2 .items}}{{.name}}{{end}}`, "ba"},
+		{`{{default "none" .missing}} {{default "none" "set"}}`, "none set"},
+		{`<script>var v = {{jsonify .names}};</script>`, `<script>var v = ["b","a"];</script>`},
+		{`{{shout "hi"}} {{truncate 1 "text"}}`, "HI custom"},
+	}
+
+	for _, c := range cases {
+		tmpl, err := template.New("t").Funcs(sw.FuncMap()).Parse(c.tmpl)
+		if !assert.NoError(t, err, c.tmpl) {
+			continue
+		}
+		sb := &strings.Builder{}
+		err = tmpl.Execute(sb, map[string]interface{}{
+			"date":  date,
+			"items": items,
+			"names": []string{"b", "a"},
+		})
+		assert.NoError(t, err, c.tmpl)
+		assert.Equal(t, c.expect, sb.String(), c.tmpl)
+	}
+
+	assert.Equal(t, "héll…", truncate(4, "héllo"))
+	assert.Equal(t, "short", truncate(10, "short"))
+}
+
+func TestSortByNode(t *testing.T) {
+	source := makeSite(t, map[string]string{
+		"a.html": "---\ntitle: Zulu\nweight: 1\n---\n",
+		"b.html": "---\ntitle: Alpha\nweight: 2\n---\n",
+	})
+	defer os.RemoveAll(source)
+
+	sw := &Swgen{Source: source, Target: filepath.Join(source, "testing"), Ignore: &dummyIgnore{}}
+	tree, err := sw.Scan(source)
+	assert.NoError(t, err)
+
+	sorted, err := sortBy("Title", tree.Children)
+	assert.NoError(t, err)
+	assert.Equal(t, "b.html", sorted.([]*Node)[0].Info.Name())
+
+	sorted, err = sortBy("Params.weight", "desc", tree.Children)
+	assert.NoError(t, err)
+	assert.Equal(t, "b.html", sorted.([]*Node)[0].Info.Name())
+	assert.Equal(t, "a.html", tree.Children[0].Info.Name())
+
+	_, err = sw.ParseTemplates(filepath.Join(source, "*.tmpl"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,102 @@
+package swgen
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// GitInfo is the commit history summary of a source file
+type GitInfo struct {
+	Created  time.Time
+	Modified time.Time
+	Author   string
+	Hash     string
+	Dirty    bool
+}
+
+const (
+	gitCommitSep = "\x1e"
+	gitFieldSep  = "\x1f"
+)
+
+// LoadGitInfo collects the history of every file under root with a single
+// git log pass, the result is keyed by the path relative to root
+func LoadGitInfo(root string) (map[string]*GitInfo, error) {
+	out, err := git(root, "log", "--no-renames", "--relative", "--name-only",
+		"--format="+gitCommitSep+"%H"+gitFieldSep+"%aI"+gitFieldSep+"%an", "--", ".")
+	if err != nil {
+		return nil, err
+	}
+
+	infos := map[string]*GitInfo{}
+	for _, commit := range strings.Split(string(out), gitCommitSep) {
+		lines := strings.Split(strings.TrimSpace(commit), "\n")
+		fields := strings.Split(lines[0], gitFieldSep)
+		if len(fields) != 3 {
+			continue
+		}
+
+		date, err := time.Parse(time.RFC3339, fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("parse commit date of %s failed: %s", fields[0], err)
+		}
+
+		// commits are listed from the newest to the oldest
+		for _, path := range lines[1:] {
+			path = strings.TrimSpace(path)
+			if path == "" {
+				continue
+			}
+
+			path = filepath.FromSlash(path)
+			info, ok := infos[path]
+			if !ok {
+				info = &GitInfo{
+					Modified: date,
+					Author:   fields[2],
+					Hash:     fields[0],
+				}
+				infos[path] = info
+			}
+			info.Created = date
+		}
+	}
+
+	// files changed in the working tree are newer than their last commit
+	prefix, err := git(root, "rev-parse", "--show-prefix")
+	if err != nil {
+		return nil, err
+	}
+	status, err := git(root, "status", "--porcelain", "-z", "--untracked-files=no", "--", ".")
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range strings.Split(string(status), "\x00") {
+		if len(entry) < 4 {
+			continue
+		}
+		path := strings.TrimPrefix(entry[3:], strings.TrimSpace(string(prefix)))
+		if info, ok := infos[filepath.FromSlash(path)]; ok {
+			info.Dirty = true
+		}
+	}
+
+	return infos, nil
+}
+
+func git(dir string, args ...string) ([]byte, error) {
+	args = append([]string{"-c", "core.quotepath=off"}, args...)
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	stderr := &bytes.Buffer{}
+	cmd.Stderr = stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git %s in %s failed: %s: %s", args[2], dir, err, strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
@@ -0,0 +1,73 @@
+package swgen
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadGitInfo(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	repo := makeSite(t, map[string]string{
+		"site/a.md":     "a",
+		"site/sub/b.md": "b",
+		"other.md":      "other",
+	})
+	defer os.RemoveAll(repo)
+
+	commit := func(author, date string, files ...string) {
+		run := func(args ...string) {
+			cmd := exec.Command("git", args...)
+			cmd.Dir = repo
+			cmd.Env = append(os.Environ(),
+				"GIT_AUTHOR_NAME="+author, "GIT_AUTHOR_EMAIL=a@example.com", "GIT_AUTHOR_DATE="+date,
+				"GIT_COMMITTER_NAME="+author, "GIT_COMMITTER_EMAIL=a@example.com", "GIT_COMMITTER_DATE="+date)
+			if out, err := cmd.CombinedOutput(); err != nil {
+				t.Fatalf("git %v: %s %s", args, err, out)
+			}
+		}
+		run(append([]string{"add"}, files...)...)
+		run("commit", "-q", "-m", date)
+	}
+
+	if out, err := exec.Command("git", "init", "-q", repo).CombinedOutput(); err != nil {
+		t.Fatalf("git init: %s %s", err, out)
+	}
+	commit("alice", "2019-01-01T00:00:00Z", "site/a.md", "other.md")
+	commit("bob", "2019-02-01T00:00:00Z", "site/sub/b.md")
+	f, _ := os.OpenFile(filepath.Join(repo, "site/a.md"), os.O_APPEND|os.O_WRONLY, 0644)
+	f.WriteString("more")
+	f.Close()
+	commit("carol", "2019-03-01T00:00:00Z", "site/a.md")
+
+	infos, err := LoadGitInfo(filepath.Join(repo, "site"))
+	assert.NoError(t, err)
+	assert.Len(t, infos, 2)
+
+	a := infos["a.md"]
+	assert.Equal(t, "carol", a.Author)
+	assert.True(t, a.Created.Equal(time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)))
+	assert.True(t, a.Modified.Equal(time.Date(2019, 3, 1, 0, 0, 0, 0, time.UTC)))
+	assert.Len(t, a.Hash, 40)
+	assert.False(t, a.Dirty)
+
+	b := infos[filepath.Join("sub", "b.md")]
+	assert.Equal(t, "bob", b.Author)
+	assert.True(t, b.Created.Equal(b.Modified))
+
+	path := filepath.Join(repo, "site/sub/b.md")
+	f, _ = os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	f.WriteString("dirty")
+	f.Close()
+	infos, err = LoadGitInfo(filepath.Join(repo, "site"))
+	assert.NoError(t, err)
+	assert.True(t, infos[filepath.Join("sub", "b.md")].Dirty)
+	assert.False(t, infos["a.md"].Dirty)
+}
@@ -3,6 +3,8 @@ module github.com/larryzju/swgen
 go 1.12
 
 require (
+	github.com/BurntSushi/toml v0.3.1
 	github.com/gobwas/glob v0.2.3
 	github.com/stretchr/testify v1.3.0
+	gopkg.in/yaml.v2 v2.4.0
 )
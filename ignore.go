@@ -27,6 +27,19 @@ func (igf *BasicIgnore) Ignore(path string) bool {
 	return false
 }
 
+// AnyIgnore ignores the path if any of the ignores does
+type AnyIgnore []Ignore
+
+func (ai AnyIgnore) Ignore(path string) bool {
+	for _, i := range ai {
+		if i.Ignore(path) {
+			return true
+		}
+	}
+
+	return false
+}
+
 func NewBasicIgnore(r io.Reader) *BasicIgnore {
 	scan := bufio.NewScanner(r)
 	bi := &BasicIgnore{}
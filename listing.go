@@ -0,0 +1,150 @@
+package swgen
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// ListingsDir is the directory of the listing templates in a template
+// directory
+const ListingsDir = "listings"
+
+// DefaultListing is the listing of a directory without the "listing" param
+const DefaultListing = "list"
+
+// Listing is the data of a listing template, it is the directory with its
+// sorted children and the files copied as is. The "listing" param of the
+// directory config picks the template listings/<name>.html, the built-in
+// ones are list, table and cards, "none" turns the listing off.
+type Listing struct {
+	*Node
+	Entries     []*Node
+	Attachments []*Attachment
+	Site        *Site
+}
+
+// Attachment is a file of the directory which is copied rather than
+// rendered, such as an image or a PDF
+type Attachment struct {
+	Info os.FileInfo
+	path string
+	sw   *Swgen
+}
+
+// Name is the file name of the attachment
+func (a *Attachment) Name() string {
+	return a.Info.Name()
+}
+
+// Size is the file size in bytes
+func (a *Attachment) Size() int64 {
+	return a.Info.Size()
+}
+
+// ModTime is the file modification time
+func (a *Attachment) ModTime() time.Time {
+	return a.Info.ModTime()
+}
+
+// URL is the link to the copied file
+func (a *Attachment) URL() (string, error) {
+	return a.sw.fileURL(a.sw.MustGetTargetPath(a.path))
+}
+
+// Summary is the "summary" or "description" param of the node, or the
+// beginning of the text of the rendered page, or of the index file of a
+// directory
+func (n *Node) Summary() (string, error) {
+	for _, key := range []string{"summary", "description"} {
+		if summary := n.Params.String(key); summary != "" {
+			return summary, nil
+		}
+	}
+
+	page := n
+	if n.Index != nil {
+		page = n.Index
+	}
+	if page.Info.IsDir() {
+		return "", nil
+	}
+
+	m := n.metadata
+	if m == nil {
+		m = &Metadata{}
+	}
+	content, err := page.renderContent(m)
+	if err != nil {
+		return "", err
+	}
+	return truncate(160, strings.Join(strings.Fields(pageText(content)), " ")), nil
+}
+
+// renderListing executes the listing template named by the directory's
+// "listing" param
+func (n *Node) renderListing(sb *strings.Builder) error {
+	name := n.Params.String("listing")
+	if name == "none" {
+		return nil
+	}
+	if name == "" {
+		name = DefaultListing
+	}
+
+	ts, err := n.listingTemplates()
+	if err != nil {
+		return err
+	}
+	tmpl := ts.listings[name]
+	if tmpl == nil {
+		return fmt.Errorf("listing %q of %s is not found", name, n.path)
+	}
+
+	listing := &Listing{
+		Node:        n,
+		Entries:     n.Children,
+		Attachments: n.Attachments,
+		Site:        n.site,
+	}
+	if err := tmpl.Execute(sb, listing); err != nil {
+		return fmt.Errorf("execute listing %s of %s failed: %s", name, n.path, ts.explain(err))
+	}
+	return nil
+}
+
+// listingTemplates is the Templates of the node, or the built-in theme if
+// the site only has the single Template
+func (n *Node) listingTemplates() (*Templates, error) {
+	if ts := n.templateSet(); ts != nil {
+		return ts, nil
+	}
+	return n.builtinTemplates()
+}
+
+// builtinTemplates is the built-in theme loaded once
+func (sw *Swgen) builtinTemplates() (*Templates, error) {
+	if sw.builtin == nil {
+		ts, err := sw.LoadTemplates()
+		if err != nil {
+			return nil, err
+		}
+		sw.builtin = ts
+	}
+	return sw.builtin, nil
+}
+
+// fileSize formats the byte count with a binary unit, such as 1.5 KB
+func fileSize(v interface{}) string {
+	size, _ := toFloat(v)
+	units := []string{"B", "KB", "MB", "GB", "TB"}
+	i := 0
+	for ; size >= 1024 && i < len(units)-1; i++ {
+		size /= 1024
+	}
+	if i == 0 {
+		return fmt.Sprintf("%d B", int64(size))
+	}
+	return fmt.Sprintf("%.1f %s", size, units[i])
+}
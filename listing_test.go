@@ -0,0 +1,155 @@
+package swgen
+
+import (
+	"html/template"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListing(t *testing.T) {
+	templates := makeSite(t, map[string]string{
+		"listings/names.html": `{{range .Entries}}[{{.Title}}]{{end}}{{range .Attachments}}({{.Name}}){{end}}`,
+	})
+	defer os.RemoveAll(templates)
+
+	source := makeSite(t, map[string]string{
+		"table/.swdir.yml": "listing: table\n",
+		"table/a.html":     "---\ntitle: Alpha\ndate: 2019-07-01\n---\n<p>The <b>first</b>\npage.</p>",
+		"table/b.html":     "---\nsummary: Second\n---\n<p>b</p>",
+		"table/report.pdf": "%PDF",
+		"cards/.swdir.yml": "listing: cards\n",
+		"cards/c.html":     "<p>c</p>",
+		"custom/d.html":    "<p>d</p>",
+		"custom/e.png":     "png",
+	})
+	defer os.RemoveAll(source)
+
+	sw := &Swgen{
+		Source:   source,
+		Target:   filepath.Join(source, "testing"),
+		Ignore:   &dummyIgnore{},
+		Template: template.Must(template.New("page").Parse(`{{.Page}}`)),
+	}
+	assert.NoError(t, sw.Run())
+
+	read := func(file string) string {
+		content, err := ioutil.ReadFile(filepath.Join(sw.Target, file))
+		assert.NoError(t, err, file)
+		return string(content)
+	}
+	table := read("table/index.html")
+	assert.Contains(t, table, `<td><a href="/table/a.html.html">Alpha</a></td>`)
+	assert.Contains(t, table, `<td>2019-07-01</td>`)
+	assert.Contains(t, table, `<td>The first page.</td>`)
+	assert.Contains(t, table, `<td>Second</td>`)
+	assert.Contains(t, table, `<a href="/table/report.pdf">report.pdf</a> <span class="size">4 B</span>`)
+	assert.Contains(t, read("cards/index.html"), `<a class="card" href="/cards/c.html.html">`)
+
+	// the site templates replace the built-in listings
+	sw.Template = nil
+	sw.Templates, _ = sw.LoadTemplates(templates)
+	sw.Force = true
+	ioutil.WriteFile(filepath.Join(source, "custom/.swdir.yml"), []byte("listing: names\n"), 0644)
+	assert.NoError(t, sw.Run())
+	assert.Contains(t, read("custom/index.html"), "[d](e.png)")
+
+	ioutil.WriteFile(filepath.Join(source, "cards/.swdir.yml"), []byte("listing: missing\n"), 0644)
+	err := sw.Run()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), `listing "missing" of`)
+	}
+}
+
+func TestSummary(t *testing.T) {
+	// kramdown stands in by a renderer counting its runs
+	runs := 0
+	markdown := RenderFns[".md"]
+	RenderFns[".md"] = func(n *Node, m *Metadata) (template.HTML, error) {
+		runs++
+		return `<h1 id="intro">Intro</h1>` + "\n" + `<p>Some <em>text</em> &amp; <a href="x.html">a link</a>.</p>`, nil
+	}
+	defer func() { RenderFns[".md"] = markdown }()
+
+	source := makeSite(t, map[string]string{
+		"notes/post.md":   "---\ntitle: Post\n---\n# Intro\n\nSome *text* & [a link](x.html).\n",
+		"guide/README.md": "# Intro\n",
+	})
+	defer os.RemoveAll(source)
+
+	sw := &Swgen{
+		Source:   source,
+		Target:   filepath.Join(source, "testing"),
+		Ignore:   &dummyIgnore{},
+		Template: template.Must(template.New("page").Parse(`{{.Summary}}`)),
+	}
+	assert.NoError(t, sw.Run())
+	assert.Equal(t, 2, runs)
+
+	content, err := ioutil.ReadFile(filepath.Join(sw.Target, "notes/post.md.html"))
+	assert.NoError(t, err)
+	assert.Equal(t, "Intro Some text &amp; a link.", string(content))
+	content, err = ioutil.ReadFile(filepath.Join(sw.Target, "guide/index.html"))
+	assert.NoError(t, err)
+	assert.Equal(t, "Intro Some text &amp; a link.", string(content))
+	// the cache is kept out of the output and follows the removed pages
+	cache := filepath.Join(sw.stateDir(), ContentDir, "notes/post.md.html")
+	_, err = os.Stat(cache)
+	assert.NoError(t, err)
+	_, err = os.Stat(filepath.Join(sw.Target, ContentDir))
+	assert.True(t, os.IsNotExist(err))
+	assert.NoError(t, os.Remove(filepath.Join(source, "notes/post.md")))
+	assert.NoError(t, sw.Run())
+	_, err = os.Stat(cache)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestListingRuns(t *testing.T) {
+	// kramdown stands in by a renderer counting its runs
+	runs := map[string]int{}
+	markdown := RenderFns[".md"]
+	RenderFns[".md"] = func(n *Node, m *Metadata) (template.HTML, error) {
+		runs[n.Info.Name()]++
+		if n.Info.Name() == "a.md" {
+			n.site.Data()
+		}
+		return template.HTML("<p>" + n.Info.Name() + "</p>"), nil
+	}
+	defer func() { RenderFns[".md"] = markdown }()
+
+	source := makeSite(t, map[string]string{
+		"table/.swdir.yml": "listing: table\n",
+		"table/a.md":       "a",
+		"table/b.md":       "b",
+	})
+	defer os.RemoveAll(source)
+
+	sw := &Swgen{
+		Source:   source,
+		Target:   filepath.Join(source, "testing"),
+		Ignore:   &dummyIgnore{},
+		Template: template.Must(template.New("page").Parse(`{{.Page}}`)),
+	}
+	assert.NoError(t, sw.Run())
+	assert.Equal(t, map[string]int{"a.md": 1, "b.md": 1}, runs)
+
+	content, err := ioutil.ReadFile(filepath.Join(sw.Target, "table/a.md.html"))
+	assert.NoError(t, err)
+	assert.Equal(t, "<p>a.md</p>", string(content))
+	// the data used by the content is marked for the page, not the listing
+	assert.Equal(t, "table/a.md\n", readState(sw, dataUsersFile))
+
+	// nothing is rendered again when nothing changes
+	assert.NoError(t, sw.Run())
+	assert.Equal(t, map[string]int{"a.md": 1, "b.md": 1}, runs)
+}
+
+func TestFileSize(t *testing.T) {
+	assert.Equal(t, "0 B", fileSize(0))
+	assert.Equal(t, "1023 B", fileSize(int64(1023)))
+	assert.Equal(t, "1.5 KB", fileSize(1536))
+	assert.Equal(t, "2.0 MB", fileSize(2*1024*1024))
+}
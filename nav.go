@@ -0,0 +1,71 @@
+package swgen
+
+// NavItem is an entry of the site navigation, see Node.Nav
+type NavItem struct {
+	Title string
+	URL   string
+	Node  *Node
+	// Active marks the page being rendered
+	Active bool
+	// InPath marks the page being rendered and its directories
+	InPath   bool
+	Children []*NavItem
+}
+
+// Nav is the navigation tree of the site from the home directory, marked
+// for the node. The entries follow the order of the listings, the nodes
+// with the "hidden" param are left out together with their children.
+func (n *Node) Nav() (*NavItem, error) {
+	inPath := map[*Node]bool{}
+	home := n
+	for up := n; up != nil; up = up.Up {
+		inPath[up] = true
+		home = up
+	}
+
+	// the tree is built once a scan and the items on the path are copied
+	// to be marked
+	if home.nav == nil {
+		nav, err := navItem(home)
+		if err != nil {
+			return nil, err
+		}
+		home.nav = nav
+	}
+	return markNav(home.nav, n, inPath), nil
+}
+
+func navItem(n *Node) (*NavItem, error) {
+	url, err := n.PageURL()
+	if err != nil {
+		return nil, err
+	}
+
+	item := &NavItem{Title: n.Title(), URL: url, Node: n}
+	for _, c := range n.Children {
+		if c.Params.Bool("hidden") {
+			continue
+		}
+		child, err := navItem(c)
+		if err != nil {
+			return nil, err
+		}
+		item.Children = append(item.Children, child)
+	}
+	return item, nil
+}
+
+func markNav(item *NavItem, current *Node, inPath map[*Node]bool) *NavItem {
+	if !inPath[item.Node] {
+		return item
+	}
+
+	marked := *item
+	marked.Active = item.Node == current
+	marked.InPath = true
+	marked.Children = make([]*NavItem, len(item.Children))
+	for i, c := range item.Children {
+		marked.Children[i] = markNav(c, current, inPath)
+	}
+	return &marked
+}
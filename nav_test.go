@@ -0,0 +1,78 @@
+package swgen
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNav(t *testing.T) {
+	source := makeSite(t, map[string]string{
+		"guide/.swdir.yml":      "title: Guide\n",
+		"guide/install.html":    "---\ntitle: Install\n---\n",
+		"guide/deep/usage.html": "<p>usage</p>",
+		"private/.swdir.yml":    "hidden: true\n",
+		"private/secret.html":   "<p>secret</p>",
+		"about.html":            "---\nhidden: true\n---\n",
+		"faq.html":              "<p>faq</p>",
+	})
+	defer os.RemoveAll(source)
+
+	sw := &Swgen{Source: source, Target: filepath.Join(source, "testing"), Ignore: &dummyIgnore{}}
+	tree, err := sw.Scan(source)
+	assert.NoError(t, err)
+
+	install := tree.Children[0].Children[1]
+	nav, err := install.Nav()
+	assert.NoError(t, err)
+	assert.Equal(t, tree, nav.Node)
+	assert.True(t, nav.InPath)
+	if assert.Len(t, nav.Children, 2) {
+		guide, faq := nav.Children[0], nav.Children[1]
+		assert.Equal(t, "Guide", guide.Title)
+		assert.Equal(t, "/guide/", guide.URL)
+		assert.True(t, guide.InPath)
+		assert.False(t, guide.Active)
+		assert.False(t, faq.InPath)
+		if assert.Len(t, guide.Children, 2) {
+			assert.Equal(t, "Install", guide.Children[1].Title)
+			assert.True(t, guide.Children[1].Active)
+		}
+	}
+
+	// the tree is shared by the nodes and marked for each
+	nav, err = nav.Children[1].Node.Nav()
+	assert.NoError(t, err)
+	assert.False(t, nav.Children[0].InPath)
+	assert.True(t, nav.Children[1].Active)
+	assert.False(t, tree.nav.InPath)
+
+	sitenav := func(file string) string {
+		content, err := ioutil.ReadFile(filepath.Join(sw.Target, file))
+		assert.NoError(t, err)
+		page := string(content)
+		page = page[strings.Index(page, `<aside class="sitenav">`):]
+		return page[:strings.Index(page, `</aside>`)]
+	}
+
+	sw.Templates, err = sw.LoadTemplates()
+	assert.NoError(t, err)
+	assert.NoError(t, sw.Run())
+	page := sitenav("guide/install.html.html")
+	assert.Contains(t, page, `<li class="open">
+    <a href="/guide/">Guide</a>`)
+	assert.Contains(t, page, `<a href="/guide/install.html.html" aria-current="page">Install</a>`)
+	assert.Contains(t, page, `<a href="/guide/deep/">deep</a>`)
+	assert.NotContains(t, page, `usage.html`)
+	assert.NotContains(t, page, `secret`)
+	assert.NotContains(t, page, `about`)
+
+	// a new page shows up in the navigation of the unchanged pages
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(source, "news.html"), []byte("<p>news</p>"), 0644))
+	assert.NoError(t, sw.Run())
+	assert.Contains(t, sitenav("faq.html.html"), `<a href="/news.html.html">news</a>`)
+}
@@ -1,6 +1,7 @@
 package swgen
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"html/template"
@@ -9,24 +10,24 @@ import (
 	"os/exec"
 	"path/filepath"
 	"strings"
+	texttemplate "text/template"
 	"time"
 )
 
-var dirTemplate = template.Must(template.New("dir").Parse(`
-<div>
-  {{.Rel}}
-  <ul>
-    {{range .Children}}
-    <li>
-      <a href="{{.PageURL}}">{{.Name}}</a>
-    </li>
-    {{end}}
-  </ul>
-</div>
-`))
-
 var NotRenderableFile = errors.New("file can not be rendered")
 
+// IndexFiles is the file names, in priority order, whose content is
+// rendered as the body of the directory's index.html
+var IndexFiles = []string{"_index.md", "_index.org", "index.md", "index.org", "README.md", "README.org"}
+
+// ContentDir is the cache of the rendered pages in the state directory,
+// see Swgen.StateDir
+const ContentDir = ".swcontent"
+
+// IndexParams is the front matter keys of an index file which apply to
+// the directory, as the index file is rendered as the directory page
+var IndexParams = []string{"title", "date", "description", "summary", "author", "weight", "layout", "aliases"}
+
 var RenderFns = map[string]RenderFn{
 	".md":   RenderMarkdown,
 	".org":  RenderOrg,
@@ -40,12 +41,34 @@ type RenderFn func(*Node, *Metadata) (template.HTML, error)
 type Node struct {
 	*Swgen
 	Info     os.FileInfo
+	Git      *GitInfo
+	Params   Params
 	path     string
+	target   string
+	body     []byte
 	Children []*Node
+	Index    *Node
 	Home     *Node
 	Next     *Node
 	Prev     *Node
 	Up       *Node
+
+	// Attachments is the files of the directory copied as is
+	Attachments []*Attachment
+	// NextInOrder and PrevInOrder link the nodes across the whole site in
+	// the reading order, see ReadingOrderParam
+	NextInOrder *Node
+	PrevInOrder *Node
+
+	// templateDir is the .template of the directory, templates is the
+	// Templates layered with it
+	templateDir string
+	templates   *Templates
+	// nav is the unmarked navigation tree of the home, see Nav
+	nav *NavItem
+	// content is the page kept by keepContent, once a build
+	content  template.HTML
+	rendered bool
 }
 
 type Metadata struct {
@@ -54,21 +77,137 @@ type Metadata struct {
 	Version   string
 }
 
-// PageURL is used to generate the HTML path
+// PageURL is used to generate the HTML path, it is derived from the
+// output file so that both of them stay consistent
 func (n *Node) PageURL() (string, error) {
-	rel, err := filepath.Rel(n.Source, n.path)
+	return n.fileURL(n.TargetFile(n))
+}
+
+// Title is the "title" param of the node, or the file name without
+// extension
+func (n *Node) Title() string {
+	if title := n.Params.String("title"); title != "" {
+		return title
+	}
+
+	name := n.Info.Name()
+	if n.Info.IsDir() {
+		return name
+	}
+	return strings.TrimSuffix(name, filepath.Ext(name))
+}
+
+// Date is the "date" param of the node, or the first commit time if it is
+// tracked by git, otherwise the file modification time
+func (n *Node) Date() time.Time {
+	if date, ok := n.Params.Time("date"); ok {
+		return date
+	}
+	if n.Git != nil {
+		return n.Git.Created
+	}
+	return n.Info.ModTime()
+}
+
+// EditURL is the link to edit the node's source in the repository, the one
+// of the index file for a directory. It is empty if the EditURL pattern is
+// not configured or the directory has no index file.
+func (n *Node) EditURL() (string, error) {
+	if n.Info.IsDir() && n.Index != nil {
+		return n.Index.EditURL()
+	}
+	if n.Swgen.EditURL == "" || n.Info.IsDir() {
+		return "", nil
+	}
+
+	if n.editURL == nil {
+		tmpl, err := texttemplate.New("edit").Parse(n.Swgen.EditURL)
+		if err != nil {
+			return "", fmt.Errorf("parse edit url pattern failed: %s", err)
+		}
+		n.editURL = tmpl
+	}
+
+	sb := &strings.Builder{}
+	if err := n.editURL.Execute(sb, n); err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
+
+// SourceURL is the link to the published raw source, the one of the index
+// file for a directory. It is empty if the source is not published or the
+// directory has no index file.
+func (n *Node) SourceURL() (string, error) {
+	if n.Info.IsDir() && n.Index != nil {
+		return n.Index.SourceURL()
+	}
+	if !n.PublishSource || n.Info.IsDir() {
+		return "", nil
+	}
+
+	rel, err := n.Rel()
 	if err != nil {
 		return "", err
 	}
+	return filepath.Join("/", n.URLRoot, rel) + ".txt", nil
+}
+
+// ModTime is the last commit time of the node if it is tracked by git and
+// not changed in the working tree, otherwise the file modification time
+func (n *Node) ModTime() time.Time {
+	if n.Git != nil && !n.Git.Dirty {
+		return n.Git.Modified
+	}
+	return n.Info.ModTime()
+}
+
+// Kind is "home" for the root directory, "dir" for other directories and
+// "page" for regular files
+func (n *Node) Kind() string {
+	switch {
+	case !n.Info.IsDir():
+		return "page"
+	case n.Up == nil:
+		return "home"
+	default:
+		return "dir"
+	}
+}
+
+// Layout is the layout named by the front matter or directory config. A
+// page without its own layout uses the page_layout of the nearest directory.
+func (n *Node) Layout() string {
+	if layout := n.Params.String("layout"); layout != "" {
+		return layout
+	}
 
-	url := filepath.Join("/", n.URLRoot, rel)
-	if !n.Info.IsDir() {
-		ext := filepath.Ext(n.Info.Name())
-		if _, ok := RenderFns[ext]; ok {
-			url += ".html"
+	if n.Info.IsDir() {
+		return ""
+	}
+
+	for up := n.Up; up != nil; up = up.Up {
+		if layout := up.Params.String("page_layout"); layout != "" {
+			return layout
 		}
 	}
-	return url, nil
+	return ""
+}
+
+// inheritedParams is the params of the node, or of its nearest directory,
+// or of the site, whichever has the key first. It is nil if none has it.
+func (n *Node) inheritedParams(key string) Params {
+	for up := n; up != nil; up = up.Up {
+		if _, ok := up.Params[key]; ok {
+			return up.Params
+		}
+	}
+	if n.site != nil {
+		if _, ok := n.site.Params[key]; ok {
+			return n.site.Params
+		}
+	}
+	return nil
 }
 
 // Rel get the node's relative path
@@ -86,6 +225,21 @@ func (n *Node) Name() (string, error) {
 	return filepath.Base(path), nil
 }
 
+// Walk calls fn on the node and all of its descendants, it stops on the
+// first error
+func (n *Node) Walk(fn func(*Node) error) error {
+	if err := fn(n); err != nil {
+		return err
+	}
+
+	for _, c := range n.Children {
+		if err := c.Walk(fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (n *Node) String() string {
 	return n.string(0)
 }
@@ -107,44 +261,178 @@ func (n *Node) Render(meta *Metadata) (template.HTML, error) {
 	if !ok {
 		return template.HTML(""), NotRenderableFile
 	}
-	return render(n, meta)
+
+	// the renderer works on a copy with the shortcodes expanded
+	body, err := n.expandShortcodes(string(n.body), nil)
+	if err != nil {
+		return template.HTML(""), err
+	}
+	expanded := *n
+	expanded.body = []byte(body)
+	return render(&expanded, meta)
 }
 
-// RenderDir render the index html file for the directory
+// renderContent is the rendered page for the summaries and feeds. It is
+// rendered once a build and kept in ContentDir, so that the pages skipped
+// by renderAll are read from there rather than rendered again.
+func (n *Node) renderContent(m *Metadata) (template.HTML, error) {
+	if n.rendered {
+		return n.content, nil
+	}
+
+	cache := n.contentFile()
+	if n.fresh(n, cache) {
+		data, err := ioutil.ReadFile(cache)
+		if err != nil {
+			return template.HTML(""), err
+		}
+		n.content, n.rendered = template.HTML(data), true
+		return n.content, nil
+	}
+
+	// the data and pages used by the content are marked for the node even
+	// if it is rendered for another one, such as a listing
+	defer n.site.nest(n)()
+	html, err := n.renderPage(m)
+	if err != nil {
+		return template.HTML(""), err
+	}
+	return html, n.keepContent(html)
+}
+
+// renderPage renders the page with its relative links kept working at the
+// output file, see resolveMoved
+func (n *Node) renderPage(m *Metadata) (template.HTML, error) {
+	html, err := n.Render(m)
+	if err != nil {
+		return template.HTML(""), err
+	}
+	return n.resolveMoved(html)
+}
+
+// keepContent keeps the rendered page for renderContent
+func (n *Node) keepContent(html template.HTML) error {
+	n.content, n.rendered = html, true
+	cache := n.contentFile()
+	if err := os.MkdirAll(filepath.Dir(cache), os.ModePerm); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(cache, []byte(html), 0644)
+}
+
+// contentFile is the cache file of the rendered page in ContentDir
+func (n *Node) contentFile() string {
+	return filepath.Join(n.stateDir(), ContentDir, n.MustGetRelPath(n.path)+".html")
+}
+
+// pruneContent deletes the cache files of the pages no longer in the tree
+func (sw *Swgen) pruneContent(tree *Node) error {
+	pages := map[string]bool{}
+	tree.Walk(func(n *Node) error {
+		for _, page := range []*Node{n, n.Index} {
+			if page != nil && !page.Info.IsDir() {
+				pages[page.contentFile()] = true
+			}
+		}
+		return nil
+	})
+
+	dir := filepath.Join(sw.stateDir(), ContentDir)
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() || pages[path] {
+			return err
+		}
+		return os.Remove(path)
+	})
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// RenderDir render the index html file for the directory, the content of
+// the index file goes first and the listing follows, see Listing
 func (n *Node) RenderDir(m *Metadata) (template.HTML, error) {
 	sb := &strings.Builder{}
-	if err := dirTemplate.Execute(sb, n); err != nil {
+	if n.Index != nil {
+		html, err := n.Index.Render(m)
+		if err != nil {
+			return template.HTML(""), err
+		}
+		if err := n.Index.keepContent(html); err != nil {
+			return template.HTML(""), err
+		}
+		sb.WriteString(string(html))
+	}
+
+	if err := n.renderListing(sb); err != nil {
 		return template.HTML(""), err
 	}
 	return template.HTML(sb.String()), nil
 }
 
-func RenderMarkdown(n *Node, m *Metadata) (template.HTML, error) {
-	cmd := exec.Command("kramdown", n.path)
-	bytes, err := cmd.Output()
-	if err != nil {
-		return template.HTML(""), err
+// pickIndex moves the first of IndexFiles among the children to the Index
+// of the directory, its IndexParams fill in the ones missing in the
+// directory config
+func (n *Node) pickIndex() {
+	for _, name := range IndexFiles {
+		for i, c := range n.Children {
+			if c.Info.IsDir() || c.Info.Name() != name {
+				continue
+			}
+
+			n.Index = c
+			n.Children = append(n.Children[:i], n.Children[i+1:]...)
+			for _, k := range IndexParams {
+				v, ok := c.Params[k]
+				if _, set := n.Params[k]; ok && !set {
+					n.Params[k] = v
+				}
+			}
+			return
+		}
 	}
-	return template.HTML(bytes), nil
+}
+
+func RenderMarkdown(n *Node, m *Metadata) (template.HTML, error) {
+	cmd := exec.Command("kramdown")
+	return runRender(n, cmd)
 }
 
 func RenderOrg(n *Node, m *Metadata) (template.HTML, error) {
-	cmd := exec.Command("pandoc", "--fail-if-warnings", "-f", "org", "-t", "html", "--mathjax", "-i", filepath.Base(n.path))
+	cmd := exec.Command("pandoc", "--fail-if-warnings", "-f", "org", "-t", "html", "--mathjax")
+	return runRender(n, cmd)
+}
+
+func RenderHTML(n *Node, m *Metadata) (template.HTML, error) {
+	return template.HTML(n.body), nil
+}
+
+// runRender feeds the page body without front matter to the command
+func runRender(n *Node, cmd *exec.Cmd) (template.HTML, error) {
 	cmd.Dir = filepath.Dir(n.path)
-	bytes, err := cmd.Output()
+	cmd.Stdin = bytes.NewReader(n.body)
+	out, err := cmd.Output()
 	if err != nil {
-		err = fmt.Errorf("run command on %s failed: %s", n.path, string(err.(*exec.ExitError).Stderr))
-		return template.HTML(""), nil
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			err = fmt.Errorf("%s", exitErr.Stderr)
+		}
+		return template.HTML(""), fmt.Errorf("run %s on %s failed: %s", cmd.Args[0], n.path, err)
 	}
 
-	return template.HTML(bytes), nil
+	return template.HTML(out), nil
 }
 
-func RenderHTML(n *Node, m *Metadata) (template.HTML, error) {
-	bytes, err := ioutil.ReadFile(n.path)
+// load reads the page source and splits the front matter from the body
+func (n *Node) load() error {
+	data, err := ioutil.ReadFile(n.path)
 	if err != nil {
-		return template.HTML(""), nil
+		return err
 	}
 
-	return template.HTML(bytes), nil
+	n.Params, n.body, err = ParseFrontMatter(filepath.Ext(n.path), data)
+	if err != nil {
+		return fmt.Errorf("parse front matter of %s failed: %s", n.path, err)
+	}
+	return nil
 }
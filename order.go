@@ -0,0 +1,31 @@
+package swgen
+
+// ReadingOrderParam is the site param choosing the nodes of the reading
+// order, "pages" for the pages only and "all" for the directories too
+const ReadingOrderParam = "reading_order"
+
+// linkReadingOrder links the nodes depth first in the order of the
+// listings, a directory goes before its children if it is included. The
+// hidden nodes are left out as in the navigation.
+func (sw *Swgen) linkReadingOrder(tree *Node) {
+	dirs := Params(sw.Params).String(ReadingOrderParam) == "all"
+
+	var prev *Node
+	var walk func(n *Node)
+	walk = func(n *Node) {
+		if n.Params.Bool("hidden") {
+			return
+		}
+		if !n.Info.IsDir() || dirs {
+			if prev != nil {
+				prev.NextInOrder = n
+				n.PrevInOrder = prev
+			}
+			prev = n
+		}
+		for _, c := range n.Children {
+			walk(c)
+		}
+	}
+	walk(tree)
+}
@@ -0,0 +1,76 @@
+package swgen
+
+import (
+	"html/template"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadingOrder(t *testing.T) {
+	source := makeSite(t, map[string]string{
+		"intro.html":             "<p>intro</p>",
+		"chapter/.swdir.yml":     "sort: -name\n",
+		"chapter/a.html":         "<p>a</p>",
+		"chapter/b.html":         "<p>b</p>",
+		"chapter/hidden.html":    "---\nhidden: true\n---\n",
+		"chapter/sub/c.html":     "<p>c</p>",
+		"appendix/glossary.html": "<p>glossary</p>",
+	})
+	defer os.RemoveAll(source)
+
+	order := func(sw *Swgen) []string {
+		tree, err := sw.Scan(source)
+		assert.NoError(t, err)
+
+		var first *Node
+		tree.Walk(func(n *Node) error {
+			if first == nil && n.PrevInOrder == nil && n.NextInOrder != nil {
+				first = n
+			}
+			return nil
+		})
+
+		names := []string{}
+		for n := first; n != nil; n = n.NextInOrder {
+			if n.NextInOrder != nil {
+				assert.Equal(t, n, n.NextInOrder.PrevInOrder)
+			}
+			names = append(names, sw.MustGetRelPath(n.path))
+		}
+		return names
+	}
+
+	sw := &Swgen{Source: source, Target: filepath.Join(source, "testing"), Ignore: &dummyIgnore{}}
+	assert.Equal(t, []string{
+		"appendix/glossary.html", "chapter/sub/c.html", "chapter/b.html", "chapter/a.html", "intro.html",
+	}, order(sw))
+
+	sw.Params = map[string]interface{}{ReadingOrderParam: "all"}
+	assert.Equal(t, []string{
+		".", "appendix", "appendix/glossary.html", "chapter", "chapter/sub", "chapter/sub/c.html",
+		"chapter/b.html", "chapter/a.html", "intro.html",
+	}, order(sw))
+
+	// the unchanged pages follow the new order without -force
+	sw = &Swgen{
+		Source:   source,
+		Target:   filepath.Join(source, "testing"),
+		Ignore:   &dummyIgnore{},
+		Template: template.Must(template.New("page").Parse(`{{with .PrevInOrder}}{{.Title}}{{end}}`)),
+	}
+	glossary := filepath.Join(sw.Target, "appendix/glossary.html.html")
+	assert.NoError(t, sw.Run())
+	content, err := ioutil.ReadFile(glossary)
+	assert.NoError(t, err)
+	assert.Equal(t, "", string(content))
+
+	sw.Params = map[string]interface{}{ReadingOrderParam: "all"}
+	assert.NoError(t, sw.Run())
+	content, err = ioutil.ReadFile(glossary)
+	assert.NoError(t, err)
+	assert.Equal(t, "appendix", string(content))
+}
@@ -0,0 +1,254 @@
+package swgen
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html"
+	"html/template"
+	"io/ioutil"
+	"log"
+	"mime"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// OutputsDir is the directory of the output format templates in a template
+// directory, they are text templates of any extension
+const OutputsDir = "outputs"
+
+// OutputFormat is a rendition of the nodes besides the HTML page, it is
+// written next to the page with the HTML extension replaced, such as
+// notes/a.md.json and notes/index.json. The built-in formats json and txt
+// need no template.
+type OutputFormat struct {
+	Name      string   `yaml:"-"`
+	Extension string   `yaml:"extension"`
+	MediaType string   `yaml:"media_type"`
+	Template  string   `yaml:"template"`
+	Kinds     []string `yaml:"kinds"`
+}
+
+// Alternate is the link to another format of the node
+type Alternate struct {
+	Name      string
+	MediaType string
+	URL       string
+}
+
+// builtinOutputs is the defaults of the built-in formats, the text is
+// named .plain.txt to keep clear of the published source
+var builtinOutputs = map[string]OutputFormat{
+	"json": {Extension: "json", MediaType: "application/json"},
+	"txt":  {Extension: "plain.txt", MediaType: "text/plain; charset=utf-8"},
+}
+
+// outputFormats is the configured formats in name order with the defaults
+// filled in
+func (sw *Swgen) outputFormats() []*OutputFormat {
+	formats := []*OutputFormat{}
+	for name, f := range sw.Outputs {
+		format := *f
+		format.Name = name
+		builtin := builtinOutputs[name]
+		if format.Extension == "" {
+			format.Extension = builtin.Extension
+		}
+		if format.Extension == "" {
+			format.Extension = name
+		}
+		if format.MediaType == "" {
+			format.MediaType = builtin.MediaType
+		}
+		if format.MediaType == "" {
+			format.MediaType = mime.TypeByExtension(filepath.Ext("." + format.Extension))
+		}
+		if len(format.Kinds) == 0 {
+			format.Kinds = []string{"page"}
+		}
+		formats = append(formats, &format)
+	}
+	sort.Slice(formats, func(i, j int) bool { return formats[i].Name < formats[j].Name })
+	return formats
+}
+
+// enabled reports whether the format is written for the node's kind
+func (f *OutputFormat) enabled(n *Node) bool {
+	for _, kind := range f.Kinds {
+		if kind == n.Kind() {
+			return true
+		}
+	}
+	return false
+}
+
+// outputFile is the file of the format next to the HTML output file
+func (sw *Swgen) outputFile(n *Node, f *OutputFormat) string {
+	dest := sw.TargetFile(n)
+	return strings.TrimSuffix(dest, filepath.Ext(dest)) + "." + f.Extension
+}
+
+// checkOutputs fails if the file of a format is the output file of a node
+// or of another format, such as a format with the extension html
+func (sw *Swgen) checkOutputs(tree *Node, targets map[string]*Node) error {
+	files := map[string]string{}
+	for target, n := range targets {
+		files[target] = n.path
+	}
+
+	formats := sw.outputFormats()
+	return tree.Walk(func(n *Node) error {
+		for _, f := range formats {
+			if !f.enabled(n) {
+				continue
+			}
+			file := sw.outputFile(n, f)
+			if other, ok := files[file]; ok {
+				return fmt.Errorf("output %s of %s collides with %s at %s", f.Name, n.path, other, file)
+			}
+			files[file] = fmt.Sprintf("output %s of %s", f.Name, n.path)
+		}
+		return nil
+	})
+}
+
+// Alternates is the links to the other formats of the node
+func (n *Node) Alternates() ([]*Alternate, error) {
+	alternates := []*Alternate{}
+	for _, f := range n.outputFormats() {
+		if !f.enabled(n) {
+			continue
+		}
+		url, err := n.fileURL(n.outputFile(n, f))
+		if err != nil {
+			return nil, err
+		}
+		alternates = append(alternates, &Alternate{Name: f.Name, MediaType: f.MediaType, URL: url})
+	}
+	return alternates, nil
+}
+
+// outputsExist reports whether the files of the enabled formats exist
+func (sw *Swgen) outputsExist(n *Node) bool {
+	for _, f := range sw.outputFormats() {
+		if !f.enabled(n) {
+			continue
+		}
+		if _, err := os.Stat(sw.outputFile(n, f)); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// renderOutputs writes the enabled formats of the node
+func (sw *Swgen) renderOutputs(n *Node, doc *Doc) error {
+	for _, f := range sw.outputFormats() {
+		if !f.enabled(n) {
+			continue
+		}
+
+		dest := sw.outputFile(n, f)
+		log.Printf("render %s as %s to %s", n.path, f.Name, dest)
+		output, err := sw.renderOutput(f, doc)
+		if err != nil {
+			if err := os.Remove(dest); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+			return fmt.Errorf("render %s as %s failed: %s", n.path, f.Name, err)
+		}
+
+		if err := os.MkdirAll(filepath.Dir(dest), os.ModePerm); err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(dest, output, 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (sw *Swgen) renderOutput(f *OutputFormat, doc *Doc) ([]byte, error) {
+	if f.Template != "" {
+		tmpl := doc.templateSet().output(f.Template)
+		if tmpl == nil {
+			return nil, fmt.Errorf("template %s of output %s is not found", f.Template, f.Name)
+		}
+		buf := &bytes.Buffer{}
+		if err := tmpl.Execute(buf, doc); err != nil {
+			return nil, doc.templateSet().explain(err)
+		}
+		return buf.Bytes(), nil
+	}
+
+	switch f.Name {
+	case "json":
+		return pageJSON(doc)
+	case "txt":
+		text := doc.Title() + "\n\n" + pageText(doc.Page) + "\n"
+		return []byte(text), nil
+	}
+	return nil, fmt.Errorf("output %s has no template", f.Name)
+}
+
+// pageJSON is the built-in json format of the node
+func pageJSON(doc *Doc) ([]byte, error) {
+	url, err := doc.PageURL()
+	if err != nil {
+		return nil, err
+	}
+
+	summary, err := doc.Summary()
+	if err != nil {
+		return nil, err
+	}
+
+	children := []string{}
+	for _, c := range doc.Children {
+		child, err := c.PageURL()
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, child)
+	}
+
+	return json.MarshalIndent(struct {
+		Title    string                 `json:"title"`
+		URL      string                 `json:"url"`
+		Kind     string                 `json:"kind"`
+		Date     time.Time              `json:"date"`
+		Modified time.Time              `json:"modified"`
+		Params   map[string]interface{} `json:"params"`
+		Summary  string                 `json:"summary"`
+		Content  template.HTML          `json:"content"`
+		Text     string                 `json:"text"`
+		Children []string               `json:"children,omitempty"`
+	}{
+		Title:    doc.Title(),
+		URL:      url,
+		Kind:     doc.Kind(),
+		Date:     doc.Date(),
+		Modified: doc.ModTime(),
+		Params:   doc.Node.Params,
+		Summary:  summary,
+		Content:  doc.Page,
+		Text:     pageText(doc.Page),
+		Children: children,
+	}, "", "  ")
+}
+
+// pageText is the text of the rendered page with the tags stripped and
+// the blank lines squeezed
+func pageText(page template.HTML) string {
+	lines := []string{}
+	for _, line := range strings.Split(html.UnescapeString(plainify(page)), "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" || (len(lines) > 0 && lines[len(lines)-1] != "") {
+			lines = append(lines, line)
+		}
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
@@ -0,0 +1,96 @@
+package swgen
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOutputFormats(t *testing.T) {
+	source := makeSite(t, map[string]string{
+		ConfigFile: `outputs:
+  json:
+    kinds: [page, dir]
+  txt:
+  search:
+    extension: search.json
+    template: search.json
+`,
+		".template/outputs/search.json": `{"title": {{jsonify .Title}}, "tags": {{jsonify .Params.tags}}}`,
+		"notes/a.html":                  "---\ntitle: Note & A\ntags: [x]\n---\n<h2>Head</h2>\n\n<p>Some &lt;text&gt;</p>",
+	})
+	defer os.RemoveAll(source)
+
+	config, err := LoadConfig(filepath.Join(source, ConfigFile))
+	assert.NoError(t, err)
+	sw := &Swgen{Source: source, Target: filepath.Join(source, "testing"), Ignore: &dummyIgnore{}}
+	assert.NoError(t, config.Apply(sw, ""))
+	sw.Templates, err = sw.LoadTemplates(filepath.Join(source, ".template"))
+	assert.NoError(t, err)
+	assert.NoError(t, sw.Run())
+
+	read := func(name string) string {
+		content, err := ioutil.ReadFile(filepath.Join(sw.Target, name))
+		assert.NoError(t, err, name)
+		return string(content)
+	}
+
+	page := map[string]interface{}{}
+	assert.NoError(t, json.Unmarshal([]byte(read("notes/a.html.json")), &page))
+	assert.Equal(t, "Note & A", page["title"])
+	assert.Equal(t, "/notes/a.html.html", page["url"])
+	assert.Equal(t, "page", page["kind"])
+	assert.Equal(t, "Head\n\nSome <text>", page["text"])
+	assert.Contains(t, page["content"], `<h2 id="head">Head</h2>`)
+
+	dir := map[string]interface{}{}
+	assert.NoError(t, json.Unmarshal([]byte(read("notes/index.json")), &dir))
+	assert.Equal(t, []interface{}{"/notes/a.html.html"}, dir["children"])
+
+	assert.Equal(t, "Note & A\n\nHead\n\nSome <text>\n", read("notes/a.html.plain.txt"))
+	assert.Equal(t, `{"title": "Note \u0026 A", "tags": ["x"]}`, read("notes/a.html.search.json"))
+	_, err = os.Stat(filepath.Join(sw.Target, "notes/index.plain.txt"))
+	assert.True(t, os.IsNotExist(err))
+
+	html := read("notes/a.html.html")
+	assert.Contains(t, html, `<link rel="alternate" type="application/json" href="/notes/a.html.json" />`)
+	assert.Contains(t, html, `<link rel="alternate" type="text/plain; charset=utf-8" href="/notes/a.html.plain.txt" />`)
+
+	// a new format renders the up to date pages again
+	past := time.Now().Add(-time.Hour)
+	output := filepath.Join(sw.Target, "notes/a.html.html")
+	assert.NoError(t, os.Chtimes(filepath.Join(source, "notes/a.html"), past.Add(-time.Hour), past.Add(-time.Hour)))
+	assert.NoError(t, os.Chtimes(output, past, past))
+	sw.Outputs["tags"] = &OutputFormat{Template: "search.json"}
+	assert.NoError(t, sw.Run())
+	assert.Equal(t, `{"title": "Note \u0026 A", "tags": ["x"]}`, read("notes/a.html.tags"))
+	info, err := os.Stat(output)
+	assert.NoError(t, err)
+	assert.True(t, info.ModTime().After(past))
+
+	// and the pages are skipped once the formats exist
+	assert.NoError(t, os.Chtimes(output, past, past))
+	assert.NoError(t, sw.Run())
+	info, err = os.Stat(output)
+	assert.NoError(t, err)
+	assert.Equal(t, past.Unix(), info.ModTime().Unix())
+
+	sw.Outputs["broken"] = &OutputFormat{Template: "missing"}
+	err = sw.Run()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "template missing of output broken is not found")
+	}
+	delete(sw.Outputs, "broken")
+
+	// a format must not replace the pages
+	sw.Outputs["page"] = &OutputFormat{Extension: "html", Template: "search.json"}
+	err = sw.Run()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "output page of "+filepath.Join(source, "notes/a.html")+" collides with")
+	}
+}
@@ -0,0 +1,209 @@
+package swgen
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// DirConfigFile is the name of the per-directory configuration file
+const DirConfigFile = ".swdir.yml"
+
+// Params is the metadata of a node, loaded from the page front matter or
+// the directory configuration
+type Params map[string]interface{}
+
+// String returns the param as a string, or empty if it is not set
+func (p Params) String(key string) string {
+	v, ok := p[key]
+	if !ok || v == nil {
+		return ""
+	}
+	return fmt.Sprint(v)
+}
+
+// Strings returns the param as a string list, a scalar value is split by
+// comma or space
+func (p Params) Strings(key string) []string {
+	return toStrings(p[key])
+}
+
+// toStrings converts a list to strings, or splits the scalar by commas and
+// spaces
+func toStrings(v interface{}) []string {
+	switch v := v.(type) {
+	case nil:
+		return nil
+	case []interface{}:
+		list := make([]string, 0, len(v))
+		for _, item := range v {
+			list = append(list, fmt.Sprint(item))
+		}
+		return list
+	default:
+		return strings.FieldsFunc(fmt.Sprint(v), func(r rune) bool {
+			return r == ',' || r == ' ' || r == '\t'
+		})
+	}
+}
+
+// Int returns the param as an integer, or 0 if it is not a number
+func (p Params) Int(key string) int {
+	switch v := p[key].(type) {
+	case int:
+		return v
+	case float64:
+		return int(v)
+	case string:
+		i, _ := strconv.Atoi(strings.TrimSpace(v))
+		return i
+	}
+	return 0
+}
+
+// Bool returns the param as a boolean
+func (p Params) Bool(key string) bool {
+	switch v := p[key].(type) {
+	case bool:
+		return v
+	case string:
+		return strings.EqualFold(v, "true") || strings.EqualFold(v, "yes") || v == "t"
+	}
+	return false
+}
+
+// dateLayouts is the accepted formats of date params
+var dateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+// Time returns the param as a time, org timestamps such as
+// <2019-07-01 Mon 10:00> are accepted as well
+func (p Params) Time(key string) (time.Time, bool) {
+	switch v := p[key].(type) {
+	case time.Time:
+		return v, true
+	case string:
+		s := strings.Trim(v, "<>[] ")
+		fields := strings.Fields(s)
+		if len(fields) >= 2 && !strings.Contains(fields[1], ":") {
+			// drop the weekday of the org timestamp
+			s = strings.Join(append(fields[:1:1], fields[2:]...), " ")
+		}
+		for _, layout := range dateLayouts {
+			if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+				return t, true
+			}
+		}
+	}
+	return time.Time{}, false
+}
+
+// ParseFrontMatter splits the source into params and body. Markdown and
+// HTML use a YAML block fenced by "---" lines, org files use the leading
+// "#+KEY: value" keywords which are kept in the body for pandoc.
+func ParseFrontMatter(ext string, data []byte) (Params, []byte, error) {
+	if ext == ".org" {
+		return parseOrgKeywords(data), data, nil
+	}
+
+	if !bytes.HasPrefix(data, []byte("---\n")) && !bytes.HasPrefix(data, []byte("---\r\n")) {
+		return Params{}, data, nil
+	}
+
+	rest := data[bytes.IndexByte(data, '\n')+1:]
+	for offset := 0; offset < len(rest); {
+		line := rest[offset:]
+		if i := bytes.IndexByte(line, '\n'); i >= 0 {
+			line = line[:i+1]
+		}
+		if strings.TrimSpace(string(line)) == "---" {
+			params, err := parseYAML(rest[:offset])
+			return params, rest[offset+len(line):], err
+		}
+		offset += len(line)
+	}
+
+	return nil, nil, fmt.Errorf("front matter is not closed")
+}
+
+func parseOrgKeywords(data []byte) Params {
+	params := Params{}
+	scan := bufio.NewScanner(bytes.NewReader(data))
+	for scan.Scan() {
+		line := strings.TrimSpace(scan.Text())
+		if line == "" || strings.HasPrefix(line, "# ") {
+			continue
+		}
+		if !strings.HasPrefix(line, "#+") {
+			break
+		}
+
+		kv := strings.SplitN(line[2:], ":", 2)
+		if len(kv) != 2 {
+			break
+		}
+		params[strings.ToLower(kv[0])] = strings.TrimSpace(kv[1])
+	}
+	return params
+}
+
+func parseYAML(data []byte) (Params, error) {
+	raw := map[string]interface{}{}
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+	return Params(normalize(raw).(map[string]interface{})), nil
+}
+
+// normalize converts the nested YAML maps to string keyed maps so that they
+// are accessible from templates
+func normalize(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			m[fmt.Sprint(k)] = normalize(item)
+		}
+		return m
+	case map[string]interface{}:
+		for k, item := range v {
+			v[k] = normalize(item)
+		}
+		return v
+	case []interface{}:
+		for i, item := range v {
+			v[i] = normalize(item)
+		}
+		return v
+	}
+	return v
+}
+
+// LoadDirConfig loads the directory configuration, a missing file results
+// in empty params
+func LoadDirConfig(path string) (Params, error) {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return Params{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	params, err := parseYAML(data)
+	if err != nil {
+		return nil, fmt.Errorf("parse %s failed: %s", path, err)
+	}
+	return params, nil
+}
@@ -0,0 +1,34 @@
+package swgen
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseFrontMatter(t *testing.T) {
+	params, body, err := ParseFrontMatter(".md", []byte("---\ntitle: Hello\nlayout: talk\ntags: [a, b]\nextra:\n  k: v\n---\n# Hello\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, "# Hello\n", string(body))
+	assert.Equal(t, "Hello", params.String("title"))
+	assert.Equal(t, "talk", params.String("layout"))
+	assert.Equal(t, []string{"a", "b"}, params.Strings("tags"))
+	assert.Equal(t, map[string]interface{}{"k": "v"}, params["extra"])
+
+	params, body, err = ParseFrontMatter(".md", []byte("# No front matter\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, "# No front matter\n", string(body))
+	assert.Empty(t, params)
+
+	_, _, err = ParseFrontMatter(".html", []byte("---\ntitle: open\n"))
+	assert.Error(t, err)
+
+	org := []byte("#+TITLE: Org page\n#+LAYOUT: talk\n#+ALIASES: a.org b.org\n\n* Heading\n#+NOT: keyword\n")
+	params, body, err = ParseFrontMatter(".org", org)
+	assert.NoError(t, err)
+	assert.Equal(t, org, body)
+	assert.Equal(t, "Org page", params.String("title"))
+	assert.Equal(t, "talk", params.String("layout"))
+	assert.Equal(t, []string{"a.org", "b.org"}, params.Strings("aliases"))
+	assert.Equal(t, "", params.String("not"))
+}
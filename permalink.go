@@ -0,0 +1,172 @@
+package swgen
+
+import (
+	"fmt"
+	"html/template"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+var permalinkToken = regexp.MustCompile(`:[a-z]+`)
+
+// Permalink is the permalink pattern of the page from the nearest directory
+// config, such as /:section/:year/:slug/. It is empty for directories.
+func (n *Node) Permalink() string {
+	if n.Info.IsDir() {
+		return ""
+	}
+
+	for up := n.Up; up != nil; up = up.Up {
+		if pattern := up.Params.String("permalink"); pattern != "" {
+			return pattern
+		}
+	}
+	return ""
+}
+
+// Slug is the "slug" param of the node, or the slugified file name without
+// extension
+func (n *Node) Slug() string {
+	if slug := n.Params.String("slug"); slug != "" {
+		return slug
+	}
+
+	name := n.Info.Name()
+	return Slugify(strings.TrimSuffix(name, filepath.Ext(name)))
+}
+
+// Section is the first directory of the node's relative path, it is empty
+// for the nodes in the source directory
+func (n *Node) Section() string {
+	rel := filepath.ToSlash(n.MustGetRelPath(n.path))
+	if i := strings.Index(rel, "/"); i >= 0 {
+		return rel[:i]
+	}
+	if n.Info.IsDir() && rel != "." {
+		return rel
+	}
+	return ""
+}
+
+// Slugify lowercases the text and replaces the characters other than
+// letters and digits with "-"
+func Slugify(s string) string {
+	sb := &strings.Builder{}
+	dash := false
+	for _, r := range strings.ToLower(s) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if dash && sb.Len() > 0 {
+				sb.WriteByte('-')
+			}
+			sb.WriteRune(r)
+			dash = false
+			continue
+		}
+		dash = true
+	}
+	return sb.String()
+}
+
+// expandPermalink replaces the tokens of the pattern with the node's values:
+// :section, :path, :year, :month, :day, :slug and :filename
+func (sw *Swgen) expandPermalink(n *Node, pattern string) (string, error) {
+	date := n.Date()
+	rel := filepath.ToSlash(sw.MustGetRelPath(n.path))
+	name := filepath.Base(rel)
+	values := map[string]string{
+		":section":  n.Section(),
+		":path":     path.Dir(rel),
+		":year":     fmt.Sprintf("%04d", date.Year()),
+		":month":    fmt.Sprintf("%02d", date.Month()),
+		":day":      fmt.Sprintf("%02d", date.Day()),
+		":slug":     n.Slug(),
+		":filename": strings.TrimSuffix(name, path.Ext(name)),
+	}
+
+	var err error
+	url := permalinkToken.ReplaceAllStringFunc(pattern, func(token string) string {
+		value, ok := values[token]
+		if !ok && err == nil {
+			err = fmt.Errorf("unknown token %s in permalink %s of %s", token, pattern, n.path)
+		}
+		return value
+	})
+	if err != nil {
+		return "", err
+	}
+
+	file := path.Clean("/" + url)
+	if strings.HasSuffix(url, "/") {
+		file = path.Join(file, "index.html")
+	} else if path.Ext(file) == "" {
+		file += ".html"
+	}
+	return filepath.Join(sw.Target, filepath.FromSlash(file)), nil
+}
+
+// assignTargets computes the output file of every node, it fails if two
+// nodes, or a node and an output format, are rendered to the same file
+func (sw *Swgen) assignTargets(tree *Node) error {
+	targets := map[string]*Node{}
+	err := tree.Walk(func(n *Node) error {
+		target := sw.TargetFile(n)
+		if pattern := n.Permalink(); pattern != "" {
+			var err error
+			if target, err = sw.expandPermalink(n, pattern); err != nil {
+				return err
+			}
+		}
+
+		if other, ok := targets[target]; ok {
+			return fmt.Errorf("%s and %s are both rendered to %s", other.path, n.path, target)
+		}
+		targets[target] = n
+		n.target = target
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return sw.checkOutputs(tree, targets)
+}
+
+// fileURL is the URL of the output file, the trailing index.html is dropped
+func (sw *Swgen) fileURL(file string) (string, error) {
+	rel, err := filepath.Rel(sw.Target, file)
+	if err != nil {
+		return "", err
+	}
+
+	url := filepath.ToSlash(filepath.Join("/", sw.URLRoot, rel))
+	if path.Base(url) == "index.html" {
+		url = strings.TrimSuffix(url, "index.html")
+	}
+	return url, nil
+}
+
+// sourceURL is the URL of the page as if it were rendered next to its
+// source, the links relative to the page are written against it
+func (n *Node) sourceURL() (string, error) {
+	if n.Info.IsDir() {
+		return n.fileURL(filepath.Join(n.MustGetTargetPath(n.path), "index.html"))
+	}
+	return n.fileURL(n.pageFile(n.path))
+}
+
+// resolveMoved resolves the relative links of a page moved away from its
+// source directory by a permalink, so that they still reach the
+// attachments copied next to the source. The links to its own fragments
+// are left as is.
+func (n *Node) resolveMoved(html template.HTML) (template.HTML, error) {
+	if n.Info.IsDir() || filepath.Dir(n.TargetFile(n)) == filepath.Dir(n.pageFile(n.path)) {
+		return html, nil
+	}
+	url, err := n.sourceURL()
+	if err != nil {
+		return template.HTML(""), err
+	}
+	return template.HTML(resolveLinks([]byte(html), url, false)), nil
+}
@@ -0,0 +1,92 @@
+package swgen
+
+import (
+	"html/template"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSlugify(t *testing.T) {
+	assert.Equal(t, "hello-world", Slugify("Hello, World!"))
+	assert.Equal(t, "kafka-101", Slugify("  Kafka 101 "))
+	assert.Equal(t, "中文-notes", Slugify("中文 notes"))
+}
+
+func TestPermalink(t *testing.T) {
+	source := makeSite(t, map[string]string{
+		"blog/.swdir.yml":       "permalink: /:section/:year/:slug/\n",
+		"blog/first.html":       "---\ndate: 2019-07-01\n---\n<p>first</p>",
+		"blog/2019/second.html": "---\ndate: 2019-08-01\nslug: the-second\n---\n<p>second</p>",
+		"notes/.swdir.yml":      "permalink: /notes/:filename\n",
+		"notes/a.html":          "<p>a</p>",
+		"other.html":            "<p>other</p>",
+	})
+	defer os.RemoveAll(source)
+
+	sw := Swgen{
+		Source:   source,
+		Target:   filepath.Join(source, "testing"),
+		URLRoot:  "wiki",
+		Ignore:   &dummyIgnore{},
+		Template: template.Must(template.New("page").Parse(`{{.PageURL}}`)),
+	}
+	assert.NoError(t, sw.Run())
+
+	for file, url := range map[string]string{
+		"blog/2019/first/index.html":      "/wiki/blog/2019/first/",
+		"blog/2019/the-second/index.html": "/wiki/blog/2019/the-second/",
+		"notes/a.html":                    "/wiki/notes/a.html",
+		"other.html.html":                 "/wiki/other.html.html",
+		"blog/index.html":                 "/wiki/blog/",
+		"index.html":                      "/wiki/",
+	} {
+		content, err := ioutil.ReadFile(filepath.Join(sw.Target, file))
+		if assert.NoError(t, err, file) {
+			assert.Equal(t, url, string(content), file)
+		}
+	}
+
+	// both pages are rendered to /blog/2019/first/
+	second := filepath.Join(source, "blog/2019/second.html")
+	assert.NoError(t, ioutil.WriteFile(second, []byte("---\ndate: 2019-08-01\nslug: first\n---\n"), 0644))
+	_, err := sw.Scan(source)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "are both rendered to")
+	}
+}
+
+func TestPermalinkLinks(t *testing.T) {
+	source := makeSite(t, map[string]string{
+		"blog/.swdir.yml":  "permalink: /:year/:slug/\n",
+		"blog/hello.html":  "---\ndate: 2020-03-01\n---\n<img src=\"pic.png\"><a href=\"#top\">top</a>",
+		"blog/pic.png":     "png",
+		"notes/.swdir.yml": "permalink: /notes/:filename\n",
+		"notes/a.html":     "<img src=\"pic.png\">",
+	})
+	defer os.RemoveAll(source)
+
+	sw := Swgen{
+		Source:   source,
+		Target:   filepath.Join(source, "testing"),
+		URLRoot:  "wiki",
+		Ignore:   &dummyIgnore{},
+		Template: template.Must(template.New("page").Parse(`{{.Page}}`)),
+	}
+	assert.NoError(t, sw.Run())
+
+	// the moved page links the attachment next to its source
+	page, err := ioutil.ReadFile(filepath.Join(sw.Target, "2020/hello/index.html"))
+	assert.NoError(t, err)
+	assert.Equal(t, `<img src="/wiki/blog/pic.png"><a href="#top">top</a>`, string(page))
+	_, err = os.Stat(filepath.Join(sw.Target, "blog/pic.png"))
+	assert.NoError(t, err)
+
+	// a page staying in its directory keeps the relative links
+	page, err = ioutil.ReadFile(filepath.Join(sw.Target, "notes/a.html"))
+	assert.NoError(t, err)
+	assert.Equal(t, `<img src="pic.png">`, string(page))
+}
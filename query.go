@@ -0,0 +1,214 @@
+package swgen
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Pages is a list of nodes to query in templates, each method returns a
+// new list so that the calls chain:
+//
+//	{{range ((.Site.RegularPages.Section "blog").Sort "-date").Limit 5}}
+//	{{range .Site.RegularPages.Where "Params.tags" "has" "kafka"}}
+//	{{range .Site.RegularPages.GroupByYear}}{{.Key}} {{len .Pages}}{{end}}
+type Pages []*Node
+
+// PageGroup is the pages sharing a key, see Pages.GroupBy
+type PageGroup struct {
+	Key   string
+	Pages Pages
+}
+
+// Pages is all the nodes of the site, the home and directories included.
+// The page calling it is rendered again when any page changes.
+func (s *Site) Pages() Pages {
+	s.usePages()
+	if s.home == nil {
+		return Pages{}
+	}
+	return append(Pages{s.home}, s.home.Pages()...)
+}
+
+// RegularPages is the pages of the site without the directories
+func (s *Site) RegularPages() Pages {
+	return s.Pages().Kind("page")
+}
+
+// Pages is the nodes below the node at any depth, the page calling it is
+// rendered again when any page changes
+func (n *Node) Pages() Pages {
+	n.site.usePages()
+	pages := Pages{}
+	for _, c := range n.Children {
+		c.Walk(func(d *Node) error {
+			pages = append(pages, d)
+			return nil
+		})
+	}
+	return pages
+}
+
+// RegularPages is the pages below the node without the directories
+func (n *Node) RegularPages() Pages {
+	return n.Pages().Kind("page")
+}
+
+func (ps Pages) filter(fn func(*Node) bool) Pages {
+	result := Pages{}
+	for _, n := range ps {
+		if fn(n) {
+			result = append(result, n)
+		}
+	}
+	return result
+}
+
+// Section is the pages below the directory, such as "blog" or "blog/2019",
+// relative to the source directory
+func (ps Pages) Section(dir string) Pages {
+	dir = strings.Trim(filepath.ToSlash(dir), "/")
+	return ps.filter(func(n *Node) bool {
+		rel := filepath.ToSlash(n.MustGetRelPath(n.path))
+		return dir == "" || strings.HasPrefix(rel, dir+"/")
+	})
+}
+
+// Kind is the pages of the kinds: home, dir and page
+func (ps Pages) Kind(kinds ...string) Pages {
+	return ps.filter(func(n *Node) bool {
+		for _, kind := range kinds {
+			if n.Kind() == kind {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// Where is the pages matching the condition, see the where function
+func (ps Pages) Where(key string, args ...interface{}) (Pages, error) {
+	result, err := where([]*Node(ps), key, args...)
+	if err != nil {
+		return nil, err
+	}
+	return Pages(result.([]*Node)), nil
+}
+
+// After is the pages dated after the time or date string
+func (ps Pages) After(date interface{}) (Pages, error) {
+	t, err := toTime(date)
+	if err != nil {
+		return nil, err
+	}
+	return ps.filter(func(n *Node) bool { return n.Date().After(t) }), nil
+}
+
+// Before is the pages dated before the time or date string
+func (ps Pages) Before(date interface{}) (Pages, error) {
+	t, err := toTime(date)
+	if err != nil {
+		return nil, err
+	}
+	return ps.filter(func(n *Node) bool { return n.Date().Before(t) }), nil
+}
+
+// Sort orders the pages by a key of the directory "sort" param: name,
+// title, weight or date, a leading "-" reverses it
+func (ps Pages) Sort(key string) (Pages, error) {
+	desc := strings.HasPrefix(key, "-")
+	fn, ok := sortKeys[strings.TrimPrefix(key, "-")]
+	if !ok {
+		return nil, fmt.Errorf("unknown sort key %q", key)
+	}
+
+	result := append(Pages{}, ps...)
+	sort.SliceStable(result, func(i, j int) bool {
+		c := fn(result[i], result[j])
+		if desc {
+			return c > 0
+		}
+		return c < 0
+	})
+	return result, nil
+}
+
+// Limit is the first n pages
+func (ps Pages) Limit(n int) Pages {
+	if n < len(ps) {
+		return append(Pages{}, ps[:n]...)
+	}
+	return append(Pages{}, ps...)
+}
+
+// Paginate is the number-th page, counted from 1, of the pages split by
+// the size
+func (ps Pages) Paginate(number, size int) Pages {
+	if number < 1 || size < 1 {
+		return Pages{}
+	}
+	start := (number - 1) * size
+	if start >= len(ps) {
+		return Pages{}
+	}
+	return ps[start:].Limit(size)
+}
+
+// PageCount is the number of pages split by the size
+func (ps Pages) PageCount(size int) int {
+	if size < 1 {
+		return 0
+	}
+	return (len(ps) + size - 1) / size
+}
+
+// GroupByYear groups the pages by the year of their dates, the latest year
+// first
+func (ps Pages) GroupByYear() []*PageGroup {
+	groups := ps.group(func(n *Node) []string {
+		return []string{strconv.Itoa(n.Date().Year())}
+	})
+	sort.SliceStable(groups, func(i, j int) bool { return groups[i].Key > groups[j].Key })
+	return groups
+}
+
+// GroupBy groups the pages by a param in the key order, a page with a list
+// param such as tags is in the group of each value. The pages without the
+// param are left out.
+func (ps Pages) GroupBy(param string) []*PageGroup {
+	groups := ps.group(func(n *Node) []string { return n.Params.Strings(param) })
+	sort.SliceStable(groups, func(i, j int) bool { return naturalCompare(groups[i].Key, groups[j].Key) < 0 })
+	return groups
+}
+
+// group keeps the order of the pages within each group
+func (ps Pages) group(keys func(*Node) []string) []*PageGroup {
+	groups := []*PageGroup{}
+	index := map[string]*PageGroup{}
+	for _, n := range ps {
+		for _, key := range keys(n) {
+			g, ok := index[key]
+			if !ok {
+				g = &PageGroup{Key: key}
+				index[key] = g
+				groups = append(groups, g)
+			}
+			g.Pages = append(g.Pages, n)
+		}
+	}
+	return groups
+}
+
+func toTime(v interface{}) (time.Time, error) {
+	if t, ok := v.(time.Time); ok {
+		return t, nil
+	}
+	t, ok := Params{"date": v}.Time("date")
+	if !ok {
+		return time.Time{}, fmt.Errorf("unknown date %v", v)
+	}
+	return t, nil
+}
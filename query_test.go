@@ -0,0 +1,125 @@
+package swgen
+
+import (
+	"html/template"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPages(t *testing.T) {
+	source := makeSite(t, map[string]string{
+		"blog/a.html":      "---\ndate: 2018-03-01\ntags: [go, kafka]\nweight: 2\n---\n",
+		"blog/b.html":      "---\ndate: 2019-05-01\ntags: kafka\nweight: 1\n---\n",
+		"blog/2019/c.html": "---\ndate: 2019-01-01\ntags: [go]\n---\n",
+		"about.html":       "---\ndate: 2017-01-01\n---\n",
+	})
+	defer os.RemoveAll(source)
+
+	sw := &Swgen{Source: source, Target: filepath.Join(source, "testing"), Ignore: &dummyIgnore{}}
+	tree, err := sw.Scan(source)
+	assert.NoError(t, err)
+	site := &Site{home: tree}
+
+	names := func(ps Pages) []string {
+		list := []string{}
+		for _, n := range ps {
+			list = append(list, filepath.ToSlash(sw.MustGetRelPath(n.path)))
+		}
+		return list
+	}
+
+	assert.Len(t, site.Pages(), 7)
+	assert.Equal(t, []string{".", "blog", "blog/2019"}, names(site.Pages().Kind("home", "dir")))
+	blog := site.RegularPages().Section("blog")
+	assert.Len(t, blog, 3)
+	assert.Equal(t, []string{"blog/2019/c.html"}, names(site.RegularPages().Section("/blog/2019/")))
+	assert.Equal(t, names(blog), names(tree.Children[0].RegularPages()))
+
+	sorted, err := blog.Sort("-date")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"blog/b.html", "blog/2019/c.html"}, names(sorted.Limit(2)))
+	_, err = blog.Sort("size")
+	assert.Error(t, err)
+
+	tagged, err := site.RegularPages().Where("Params.tags", "has", "kafka")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"blog/a.html", "blog/b.html"}, names(tagged))
+	heavy, err := blog.Where("Params.weight", ">", 1)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"blog/a.html"}, names(heavy))
+
+	recent, err := site.RegularPages().After("2018-12-31")
+	assert.NoError(t, err)
+	assert.Len(t, recent, 2)
+	old, err := site.RegularPages().Before("2018-01-01")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"about.html"}, names(old))
+	_, err = blog.After("someday")
+	assert.Error(t, err)
+
+	all := site.RegularPages()
+	assert.Equal(t, 2, all.PageCount(3))
+	assert.Equal(t, names(all[3:]), names(all.Paginate(2, 3)))
+	assert.Empty(t, all.Paginate(3, 3))
+
+	years := sorted.GroupByYear()
+	if assert.Len(t, years, 2) {
+		assert.Equal(t, "2019", years[0].Key)
+		assert.Equal(t, []string{"blog/b.html", "blog/2019/c.html"}, names(years[0].Pages))
+	}
+	tags := blog.GroupBy("tags")
+	if assert.Len(t, tags, 2) {
+		assert.Equal(t, "go", tags[0].Key)
+		assert.Equal(t, []string{"blog/2019/c.html", "blog/a.html"}, names(tags[0].Pages))
+		assert.Equal(t, "kafka", tags[1].Key)
+	}
+
+	// the queries chain in templates
+	sw.Template = template.Must(template.New("page").Funcs(sw.FuncMap()).Parse(
+		`{{range (((.Site.RegularPages.Section "blog").Sort "-date") | first 2)}}{{.Name}} {{end}}` +
+			`{{range .Site.RegularPages.GroupByYear}}{{.Key}}:{{len .Pages}} {{end}}`))
+	assert.NoError(t, sw.Run())
+	content, err := ioutil.ReadFile(filepath.Join(sw.Target, "about.html.html"))
+	assert.NoError(t, err)
+	assert.Equal(t, "b.html c.html 2019:2 2018:1 2017:1 ", string(content))
+}
+
+func TestPagesUsers(t *testing.T) {
+	source := makeSite(t, map[string]string{
+		"tags.html":  "---\nlayout: tags\n---\n",
+		"about.html": "<p>about</p>",
+		"a.html":     "---\ntags: [go]\n---\n",
+		"b.html":     "---\ntags: [kafka]\n---\n",
+	})
+	defer os.RemoveAll(source)
+
+	layouts := template.Must(template.New("page").Parse(`{{.Page}}`))
+	template.Must(layouts.New("tags").Parse(
+		`{{range .Site.RegularPages.Where "Params.tags" "has" "go"}}{{.Title}}{{end}}`))
+	sw := &Swgen{Source: source, Target: filepath.Join(source, "testing"), Ignore: &dummyIgnore{}, Template: layouts}
+	assert.NoError(t, sw.Run())
+
+	read := func(file string) string {
+		content, err := ioutil.ReadFile(filepath.Join(sw.Target, file))
+		assert.NoError(t, err)
+		return string(content)
+	}
+	assert.Equal(t, "a", read("tags.html.html"))
+	assert.Equal(t, "tags.html\n", readState(sw, pagesUsersFile))
+
+	// the unchanged page querying the pages follows a change of another
+	// page, the others are skipped
+	stale := []byte("stale")
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(sw.Target, "about.html.html"), stale, 0644))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(source, "b.html"), []byte("---\ntags: [go]\n---\n"), 0644))
+	future := time.Now().Add(time.Hour)
+	assert.NoError(t, os.Chtimes(filepath.Join(source, "b.html"), future, future))
+	assert.NoError(t, sw.Run())
+	assert.Equal(t, "ab", read("tags.html.html"))
+	assert.Equal(t, "stale", read("about.html.html"))
+}
@@ -0,0 +1,101 @@
+package swgen
+
+import (
+	"net/url"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// urlAttr matches the site absolute URLs in the link attributes of the
+// rendered HTML, such as href="/notes/a.html"
+var urlAttr = regexp.MustCompile(`(\s(?:href|src|action|poster)=)("/[^"]*"|'/[^']*')`)
+
+// linkAttr matches the URLs of any form in the link attributes
+var linkAttr = regexp.MustCompile(`(\s(?:href|src|action|poster)=)("[^"]*"|'[^']*')`)
+
+// resolveURLs resolves the document relative URLs in the HTML against the
+// site absolute URL of the page, such as img.png of /notes/a.html to
+// /notes/img.png, the URLs with a scheme or a host are left as is
+func resolveURLs(html []byte, page string) []byte {
+	return resolveLinks(html, page, true)
+}
+
+// resolveLinks is resolveURLs leaving the links to the fragments of the
+// page itself, such as #top, as is unless fragments is set
+func resolveLinks(html []byte, page string, fragments bool) []byte {
+	base, err := url.Parse(page)
+	if err != nil {
+		return html
+	}
+	return linkAttr.ReplaceAllFunc(html, func(attr []byte) []byte {
+		m := linkAttr.FindSubmatch(attr)
+		quote := m[2][:1]
+		link := string(m[2][1 : len(m[2])-1])
+		ref, err := url.Parse(link)
+		if err != nil || ref.Scheme != "" || ref.Host != "" || strings.HasPrefix(link, "/") ||
+			(!fragments && strings.HasPrefix(link, "#")) {
+			return attr
+		}
+		return []byte(string(m[1]) + string(quote) + base.ResolveReference(ref).String() + string(quote))
+	})
+}
+
+// relativize rewrites the site absolute URLs in the HTML of the output file
+// to the paths relative to it, see Swgen.RelativeURLs
+func (sw *Swgen) relativize(html []byte, dest string) []byte {
+	return urlAttr.ReplaceAllFunc(html, func(attr []byte) []byte {
+		m := urlAttr.FindSubmatch(attr)
+		quote := m[2][:1]
+		url := string(m[2][1 : len(m[2])-1])
+		return []byte(string(m[1]) + string(quote) + sw.relativeURL(url, dest) + string(quote))
+	})
+}
+
+// relativeURL is the URL relative to the output file. A directory URL ends
+// with index.html so that the link works without a web server, the URLs
+// outside of the URL root are returned as is.
+func (sw *Swgen) relativeURL(url, dest string) string {
+	p, suffix := url, ""
+	if i := strings.IndexAny(url, "?#"); i >= 0 {
+		p, suffix = url[:i], url[i:]
+	}
+	if !strings.HasPrefix(p, "/") || strings.HasPrefix(p, "//") {
+		return url
+	}
+
+	if root := path.Join("/", sw.URLRoot); root != "/" {
+		if p != root && !strings.HasPrefix(p, root+"/") {
+			return url
+		}
+		p = strings.TrimPrefix(p, root)
+		if p == "" {
+			p = "/"
+		}
+	}
+
+	file := filepath.Join(sw.Target, filepath.FromSlash(p))
+	if strings.HasSuffix(p, "/") {
+		file = filepath.Join(file, "index.html")
+	}
+	rel, err := filepath.Rel(filepath.Dir(dest), file)
+	if err != nil {
+		return url
+	}
+	return filepath.ToSlash(rel) + suffix
+}
+
+// absolutize prefixes the site absolute URLs in the HTML with the base URL,
+// for the content leaving the site such as the feeds
+func (sw *Swgen) absolutize(html []byte) []byte {
+	return urlAttr.ReplaceAllFunc(html, func(attr []byte) []byte {
+		m := urlAttr.FindSubmatch(attr)
+		quote := m[2][:1]
+		url := string(m[2][1 : len(m[2])-1])
+		if !strings.HasPrefix(url, "//") {
+			url = sw.absoluteURL(url)
+		}
+		return []byte(string(m[1]) + string(quote) + url + string(quote))
+	})
+}
@@ -0,0 +1,87 @@
+package swgen
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRelativeURL(t *testing.T) {
+	sw := &Swgen{Target: "/out", URLRoot: "wiki"}
+	page := "/out/notes/a.html"
+	for url, expect := range map[string]string{
+		"/wiki/notes/b.html":    "b.html",
+		"/wiki/notes/b.html#s1": "b.html#s1",
+		"/wiki/":                "../index.html",
+		"/wiki":                 "../index.html",
+		"/wiki/guide/?q=1":      "../guide/index.html?q=1",
+		"/wiki/_swgen/a.css":    "../_swgen/a.css",
+		"/other/page.html":      "/other/page.html",
+		"//cdn.example/a.js":    "//cdn.example/a.js",
+		"https://example.com/":  "https://example.com/",
+		"#top":                  "#top",
+	} {
+		assert.Equal(t, expect, sw.relativeURL(url, page), url)
+	}
+
+	html := `<a href="/wiki/"><img src='/wiki/notes/x.png'> <a data-x="/wiki/">`
+	assert.Equal(t, `<a href="../index.html"><img src='x.png'> <a data-x="/wiki/">`,
+		string(sw.relativize([]byte(html), page)))
+}
+
+func TestResolveURLs(t *testing.T) {
+	html := `<img src="img.png"> <a href='../x.html#s1'> <a href="#top"> <a href="/wiki/y.html"> ` +
+		`<a href="https://example.com/z"> <img src="//cdn.example/a.png">`
+	assert.Equal(t, `<img src="/wiki/notes/img.png"> <a href='/wiki/x.html#s1'> <a href="/wiki/notes/a.html#top"> `+
+		`<a href="/wiki/y.html"> <a href="https://example.com/z"> <img src="//cdn.example/a.png">`,
+		string(resolveURLs([]byte(html), "/wiki/notes/a.html")))
+}
+
+func TestRelativeURLs(t *testing.T) {
+	source := makeSite(t, map[string]string{
+		"notes/a.html": "---\naliases: [old.html]\n---\n<a href=\"/wiki/notes/b.html.html\">b</a>",
+		"notes/b.html": "<p>b</p>",
+	})
+	defer os.RemoveAll(source)
+
+	sw := &Swgen{
+		Source:       source,
+		Target:       filepath.Join(source, "testing"),
+		URLRoot:      "wiki",
+		Ignore:       &dummyIgnore{},
+		RelativeURLs: true,
+	}
+	templates := makeSite(t, map[string]string{
+		"404.html": `{{define "main"}}<a href="/wiki/notes/">notes</a>{{end}}`,
+	})
+	defer os.RemoveAll(templates)
+	var err error
+	sw.Templates, err = sw.LoadTemplates(templates)
+	assert.NoError(t, err)
+	assert.NoError(t, sw.Run())
+
+	read := func(name string) string {
+		content, err := ioutil.ReadFile(filepath.Join(sw.Target, name))
+		assert.NoError(t, err, name)
+		return string(content)
+	}
+	page := read("notes/a.html.html")
+	assert.Contains(t, page, `<a href="b.html.html">b</a>`)
+	assert.Contains(t, page, `<link rel="stylesheet" href="../_swgen/swgen.css" />`)
+	assert.Contains(t, page, `<a class="home" href="../index.html">`)
+	assert.Contains(t, page, `<a class="up" href="index.html">`)
+	assert.Contains(t, read("notes/index.html"), `<a href="a.html.html">a</a>`)
+	assert.Contains(t, read("old.html.html"), `url=notes/a.html.html`)
+	assert.Contains(t, read(RedirectsFile), "/wiki/old.html.html /wiki/notes/a.html.html 301")
+	// 404.html is shown at any missing path
+	assert.Contains(t, read("404.html"), `<a href="/wiki/notes/">notes</a>`)
+	assert.Contains(t, read("404.html"), `href="/wiki/_swgen/swgen.css"`)
+
+	// the pages are rendered again when the setting changes
+	sw.RelativeURLs = false
+	assert.NoError(t, sw.Run())
+	assert.Contains(t, read("notes/a.html.html"), `<a href="/wiki/notes/b.html.html">b</a>`)
+}
@@ -0,0 +1,224 @@
+package swgen
+
+import (
+	"fmt"
+	"html/template"
+	"strconv"
+	"strings"
+)
+
+// ShortcodesDir is the directory of the shortcode templates in a template
+// directory
+const ShortcodesDir = "shortcodes"
+
+const (
+	shortcodeOpen  = "{{<"
+	shortcodeClose = ">}}"
+)
+
+// Shortcode is the data of a shortcode template. A shortcode is written as
+// {{< name arg key="value" >}} in the page source, or wraps inner content
+// up to {{< /name >}}. It is expanded before the page is rendered, through
+// the template shortcodes/<name>.html. {{</* name */>}} is kept literally.
+type Shortcode struct {
+	Name   string
+	Args   []string
+	Params map[string]string
+	Inner  template.HTML
+	Node   *Node
+	Site   *Site
+	Parent *Shortcode
+}
+
+// Get returns the positional argument by index, or the named param by key
+func (sc *Shortcode) Get(key interface{}) string {
+	switch k := key.(type) {
+	case int:
+		if k >= 0 && k < len(sc.Args) {
+			return sc.Args[k]
+		}
+		return ""
+	default:
+		return sc.Params[fmt.Sprint(k)]
+	}
+}
+
+// expandShortcodes replaces the shortcodes in the text with their output,
+// the output is wrapped as raw HTML for org files
+func (n *Node) expandShortcodes(text string, parent *Shortcode) (string, error) {
+	sb := &strings.Builder{}
+	for {
+		start := strings.Index(text, shortcodeOpen)
+		if start < 0 {
+			sb.WriteString(text)
+			return sb.String(), nil
+		}
+		sb.WriteString(text[:start])
+
+		end := strings.Index(text[start:], shortcodeClose)
+		if end < 0 {
+			return "", fmt.Errorf("shortcode in %s is not closed: %s", n.path, excerpt(text[start:]))
+		}
+		tag := strings.TrimSpace(text[start+len(shortcodeOpen) : start+end])
+		rest := text[start+end+len(shortcodeClose):]
+
+		if strings.HasPrefix(tag, "/*") && strings.HasSuffix(tag, "*/") {
+			sb.WriteString(shortcodeOpen + " " + strings.TrimSpace(tag[2:len(tag)-2]) + " " + shortcodeClose)
+			text = rest
+			continue
+		}
+		if strings.HasPrefix(tag, "/") {
+			return "", fmt.Errorf("unexpected closing shortcode %s in %s", tag, n.path)
+		}
+
+		selfClosing := strings.HasSuffix(tag, "/")
+		sc, err := parseShortcode(strings.TrimSuffix(tag, "/"))
+		if err != nil {
+			return "", fmt.Errorf("parse shortcode %s in %s failed: %s", excerpt(tag), n.path, err)
+		}
+		sc.Node, sc.Site, sc.Parent = n, n.site, parent
+
+		if !selfClosing {
+			if inner, after, ok := findClosing(rest, sc.Name); ok {
+				expanded, err := n.expandShortcodes(inner, sc)
+				if err != nil {
+					return "", err
+				}
+				sc.Inner = template.HTML(expanded)
+				rest = after
+			}
+		}
+
+		output, err := n.executeShortcode(sc)
+		if err != nil {
+			return "", err
+		}
+		if parent == nil && n.Info != nil && strings.HasSuffix(n.Info.Name(), ".org") {
+			output = "\n#+BEGIN_EXPORT html\n" + output + "\n#+END_EXPORT\n"
+		}
+		sb.WriteString(output)
+		text = rest
+	}
+}
+
+func (n *Node) executeShortcode(sc *Shortcode) (string, error) {
+	var tmpl *template.Template
+	ts := n.templateSet()
+	if ts != nil {
+		tmpl = ts.shortcodes[sc.Name]
+	}
+	if tmpl == nil {
+		return "", fmt.Errorf("shortcode %s used in %s is not found", sc.Name, n.path)
+	}
+
+	sb := &strings.Builder{}
+	if err := tmpl.Execute(sb, sc); err != nil {
+		return "", fmt.Errorf("execute shortcode %s in %s failed: %s", sc.Name, n.path, ts.explain(err))
+	}
+	return sb.String(), nil
+}
+
+// findClosing finds the closing tag of the shortcode, the nested ones of
+// the same name are skipped. It returns the inner content and the text
+// after the closing tag.
+func findClosing(text, name string) (string, string, bool) {
+	depth := 0
+	offset := 0
+	for {
+		start := strings.Index(text[offset:], shortcodeOpen)
+		if start < 0 {
+			return "", "", false
+		}
+		start += offset
+		end := strings.Index(text[start:], shortcodeClose)
+		if end < 0 {
+			return "", "", false
+		}
+		end += start
+
+		tag := strings.TrimSpace(text[start+len(shortcodeOpen) : end])
+		fields := strings.Fields(strings.TrimSuffix(tag, "/"))
+		switch {
+		case len(fields) == 0:
+		case fields[0] == "/"+name || (fields[0] == "/" && len(fields) > 1 && fields[1] == name):
+			if depth == 0 {
+				return text[:start], text[end+len(shortcodeClose):], true
+			}
+			depth--
+		case fields[0] == name && !strings.HasSuffix(tag, "/"):
+			depth++
+		}
+		offset = end + len(shortcodeClose)
+	}
+}
+
+// parseShortcode splits the tag into the name, positional arguments and
+// named params, the values may be double quoted
+func parseShortcode(tag string) (*Shortcode, error) {
+	tokens, err := splitArgs(tag)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("shortcode name is missing")
+	}
+
+	sc := &Shortcode{Name: tokens[0], Params: map[string]string{}}
+	for _, token := range tokens[1:] {
+		if i := strings.Index(token, "="); i > 0 && !strings.HasPrefix(token, `"`) {
+			value := token[i+1:]
+			if unquoted, err := strconv.Unquote(value); err == nil {
+				value = unquoted
+			}
+			sc.Params[token[:i]] = value
+			continue
+		}
+
+		if unquoted, err := strconv.Unquote(token); err == nil {
+			token = unquoted
+		}
+		sc.Args = append(sc.Args, token)
+	}
+	return sc, nil
+}
+
+// splitArgs splits the text by spaces outside of double quotes, the quotes
+// are kept in the tokens
+func splitArgs(text string) ([]string, error) {
+	tokens := []string{}
+	sb := &strings.Builder{}
+	quoted, escaped := false, false
+	for _, r := range text {
+		switch {
+		case escaped:
+			escaped = false
+		case quoted && r == '\\':
+			escaped = true
+		case r == '"':
+			quoted = !quoted
+		case !quoted && (r == ' ' || r == '\t' || r == '\n'):
+			if sb.Len() > 0 {
+				tokens = append(tokens, sb.String())
+				sb.Reset()
+			}
+			continue
+		}
+		sb.WriteRune(r)
+	}
+
+	if quoted {
+		return nil, fmt.Errorf("quote is not closed")
+	}
+	if sb.Len() > 0 {
+		tokens = append(tokens, sb.String())
+	}
+	return tokens, nil
+}
+
+// excerpt is the first line of the text cut to 40 characters
+func excerpt(text string) string {
+	if i := strings.IndexByte(text, '\n'); i >= 0 {
+		text = text[:i]
+	}
+	return truncate(40, text)
+}
@@ -0,0 +1,77 @@
+package swgen
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseShortcode(t *testing.T) {
+	sc, err := parseShortcode(`figure src="a b.png" caption="say \"hi\"" wide 3`)
+	assert.NoError(t, err)
+	assert.Equal(t, "figure", sc.Name)
+	assert.Equal(t, map[string]string{"src": "a b.png", "caption": `say "hi"`}, sc.Params)
+	assert.Equal(t, []string{"wide", "3"}, sc.Args)
+	assert.Equal(t, "wide", sc.Get(0))
+	assert.Equal(t, "a b.png", sc.Get("src"))
+	assert.Equal(t, "", sc.Get(5))
+
+	_, err = parseShortcode(`figure src="open`)
+	assert.Error(t, err)
+}
+
+func TestShortcodes(t *testing.T) {
+	templates := makeSite(t, map[string]string{
+		"shortcodes/box.html":   `<div class="{{.Get 0}}">{{.Inner}}</div>`,
+		"shortcodes/next.html":  `{{with .Node.Next}}<a href="{{.PageURL}}">{{.Title}}</a>{{end}}`,
+		"shortcodes/depth.html": `{{with .Parent}}{{.Name}}{{else}}top{{end}}`,
+	})
+	defer os.RemoveAll(templates)
+
+	source := makeSite(t, map[string]string{
+		"a.html": `{{< box outer >}}[{{< box inner >}}{{< depth >}}{{< /box >}}]{{< /box >}}` +
+			` {{< next >}} {{< figure src="x.png" caption="X" />}} {{</* box */>}}`,
+		"b.html": `{{< missing >}}`,
+		"c.html": `{{< box open`,
+	})
+	defer os.RemoveAll(source)
+
+	sw := &Swgen{Source: source, Target: filepath.Join(source, "testing"), Ignore: &dummyIgnore{}}
+	var err error
+	sw.Templates, err = sw.LoadTemplates(templates)
+	assert.NoError(t, err)
+	tree, err := sw.Scan(source)
+	assert.NoError(t, err)
+
+	html, err := tree.Children[0].Render(nil)
+	assert.NoError(t, err)
+	assert.Contains(t, string(html), `<div class="outer">[<div class="inner">box</div>]</div>`)
+	assert.Contains(t, string(html), `<a href="/b.html.html">b</a>`)
+	assert.Contains(t, string(html), `<img src="x.png" alt="X" />`)
+	assert.Contains(t, string(html), `{{< box >}}`)
+
+	_, err = tree.Children[1].Render(nil)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "shortcode missing used in")
+	}
+	_, err = tree.Children[2].Render(nil)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "is not closed")
+	}
+
+	// org files get the output as raw HTML
+	org := &Node{Swgen: sw, path: filepath.Join(source, "d.org"), Info: tree.Children[0].Info}
+	body, err := org.expandShortcodes(`{{< box x >}}`, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, `<div class="x"></div>`, body)
+
+	ioutil.WriteFile(filepath.Join(source, "d.org"), []byte("{{< box x >}}"), 0644)
+	info, _ := os.Stat(filepath.Join(source, "d.org"))
+	org.Info = info
+	body, err = org.expandShortcodes(`{{< box x >}}`, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "\n#+BEGIN_EXPORT html\n<div class=\"x\"></div>\n#+END_EXPORT\n", body)
+}
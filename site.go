@@ -0,0 +1,216 @@
+package swgen
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// dataUsersFile records the pages using the data files in the state
+// directory, so that they are rendered again when the data changes
+const dataUsersFile = ".swdata"
+
+// pagesUsersFile records the pages querying the other pages, such as by
+// .Site.RegularPages, they are rendered again when any page changes
+const pagesUsersFile = ".swpages"
+
+// treeFile records the shape of the tree and the build settings in the
+// state directory, all the pages are rendered again when it changes since
+// they link each other
+const treeFile = ".swtree"
+
+// Site is the site wide information available to templates as .Site
+type Site struct {
+	Params  map[string]interface{}
+	Profile string
+
+	data     map[string]interface{}
+	dataTime time.Time
+	// home is the scanned tree, see Pages
+	home *Node
+
+	// rendering is the node being rendered, it marks the data users and
+	// the pages users
+	rendering  *Node
+	dataUsers  map[string]bool
+	pagesUsers map[string]bool
+	// pagesTime is the latest modification time of the pages
+	pagesTime time.Time
+
+	// tree is the shape of the scanned tree, treeChanged reports whether
+	// it differs from the one of the last build
+	tree        string
+	treeChanged bool
+}
+
+// Data is the tree loaded from the data files, such as .Site.Data.services
+func (s *Site) Data() map[string]interface{} {
+	if s.rendering != nil {
+		s.dataUsers[s.rendering.MustGetRelPath(s.rendering.path)] = true
+	}
+	return s.data
+}
+
+func (sw *Swgen) loadSite() (*Site, error) {
+	data, dataTime, err := LoadData(filepath.Join(sw.Source, DataDir))
+	if err != nil {
+		return nil, err
+	}
+
+	site := &Site{
+		Params:   sw.Params,
+		Profile:  sw.Profile,
+		data:     data,
+		dataTime: dataTime,
+	}
+
+	if site.dataUsers, err = loadUsers(filepath.Join(sw.stateDir(), dataUsersFile)); err != nil {
+		return nil, err
+	}
+	if site.pagesUsers, err = loadUsers(filepath.Join(sw.stateDir(), pagesUsersFile)); err != nil {
+		return nil, err
+	}
+	return site, nil
+}
+
+// usePages marks the node being rendered as a pages user
+func (s *Site) usePages() {
+	if s != nil && s.rendering != nil {
+		s.pagesUsers[s.rendering.MustGetRelPath(s.rendering.path)] = true
+	}
+}
+
+// begin marks the node as being rendered, it is a data or pages user only
+// if it reads them again
+func (s *Site) begin(n *Node) {
+	s.rendering = n
+	delete(s.dataUsers, n.MustGetRelPath(n.path))
+	delete(s.pagesUsers, n.MustGetRelPath(n.path))
+}
+
+// nest marks the node as being rendered within the one being rendered,
+// the returned func marks the outer one again
+func (s *Site) nest(n *Node) func() {
+	if s == nil {
+		return func() {}
+	}
+	outer := s.rendering
+	s.begin(n)
+	return func() { s.rendering = outer }
+}
+
+// end stops marking the users once the pages are rendered
+func (s *Site) end() {
+	s.rendering = nil
+}
+
+// dataChanged checks whether the node used the data files which are newer
+// than its output
+func (s *Site) dataChanged(n *Node, output time.Time) bool {
+	return s.dataUsers[n.MustGetRelPath(n.path)] && s.dataTime.After(output)
+}
+
+// pagesChanged checks whether the node queried the pages and any of them
+// is newer than its output
+func (s *Site) pagesChanged(n *Node, output time.Time) bool {
+	return s.pagesUsers[n.MustGetRelPath(n.path)] && s.pagesTime.After(output)
+}
+
+func (s *Site) saveUsers(state string) error {
+	if err := saveUsers(filepath.Join(state, dataUsersFile), s.dataUsers); err != nil {
+		return err
+	}
+	return saveUsers(filepath.Join(state, pagesUsersFile), s.pagesUsers)
+}
+
+// loadUsers reads the relative paths of the pages, one a line
+func loadUsers(path string) (map[string]bool, error) {
+	users := map[string]bool{}
+	fd, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return users, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer fd.Close()
+
+	scan := bufio.NewScanner(fd)
+	for scan.Scan() {
+		if line := strings.TrimSpace(scan.Text()); line != "" {
+			users[line] = true
+		}
+	}
+	return users, scan.Err()
+}
+
+func saveUsers(path string, users map[string]bool) error {
+	lines := []string{}
+	for rel := range users {
+		lines = append(lines, rel+"\n")
+	}
+	sort.Strings(lines)
+
+	fd, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer fd.Close()
+
+	_, err = fd.WriteString(strings.Join(lines, ""))
+	return err
+}
+
+// treeShape is the build settings changing the links and the layouts of
+// all the pages, followed by a line for each node in the walk order with
+// what the navigation and the pager show of it: the path, the output file,
+// the title, hidden, its neighbours in the reading order and the templates
+// of its own .template
+func treeShape(tree *Node) string {
+	rel := func(n *Node) string {
+		if n == nil {
+			return ""
+		}
+		return n.MustGetRelPath(n.path)
+	}
+
+	sb := &strings.Builder{}
+	fmt.Fprintf(sb, "%s\t%t\t%s\t%s\t%s\n", tree.URLRoot, tree.RelativeURLs, tree.BaseURL,
+		tree.Swgen.Profile, tree.Templates.fingerprint())
+	tree.Walk(func(n *Node) error {
+		fmt.Fprintf(sb, "%s\t%s\t%s\t%t\t%s\t%s\t%s\n", rel(n), n.MustGetRelPath(n.TargetFile(n)),
+			n.Title(), n.Params.Bool("hidden"), rel(n.PrevInOrder), rel(n.NextInOrder),
+			n.templates.fingerprint())
+		return nil
+	})
+	return sb.String()
+}
+
+// checkTree compares the shape of the tree with the one of the last build
+// and finds the latest modification time of the pages
+func (s *Site) checkTree(state string, tree *Node) error {
+	s.tree = treeShape(tree)
+	tree.Walk(func(n *Node) error {
+		for _, page := range []*Node{n, n.Index} {
+			if page != nil && page.ModTime().After(s.pagesTime) {
+				s.pagesTime = page.ModTime()
+			}
+		}
+		return nil
+	})
+	data, err := ioutil.ReadFile(filepath.Join(state, treeFile))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	s.treeChanged = string(data) != s.tree
+	return nil
+}
+
+func (s *Site) saveTree(state string) error {
+	return ioutil.WriteFile(filepath.Join(state, treeFile), []byte(s.tree), 0644)
+}
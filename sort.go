@@ -0,0 +1,125 @@
+package swgen
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// sortKeys compares two nodes by a sort key, it returns a negative number
+// if a goes before b
+var sortKeys = map[string]func(a, b *Node) int{
+	"name": func(a, b *Node) int {
+		return naturalCompare(a.Info.Name(), b.Info.Name())
+	},
+	"title": func(a, b *Node) int {
+		return naturalCompare(a.Title(), b.Title())
+	},
+	"weight": func(a, b *Node) int {
+		return a.Params.Int("weight") - b.Params.Int("weight")
+	},
+	"date": func(a, b *Node) int {
+		da, db := a.Date(), b.Date()
+		switch {
+		case da.Before(db):
+			return -1
+		case da.After(db):
+			return 1
+		}
+		return 0
+	},
+}
+
+// defaultOrder puts the directories first and then the natural order names
+func defaultOrder(a, b *Node) int {
+	if a.Info.IsDir() != b.Info.IsDir() {
+		if a.Info.IsDir() {
+			return -1
+		}
+		return 1
+	}
+	return sortKeys["name"](a, b)
+}
+
+// sortChildren orders the children of the directory. The "sort" param
+// names the key: name, title, weight or date, a leading "-" reverses it.
+// The names in the "order" param go first in the listed order.
+func sortChildren(n *Node) error {
+	key := n.Params.String("sort")
+	desc := strings.HasPrefix(key, "-")
+	key = strings.TrimPrefix(key, "-")
+
+	compare := defaultOrder
+	if key != "" {
+		fn, ok := sortKeys[key]
+		if !ok {
+			return fmt.Errorf("unknown sort key %q of %s", key, n.path)
+		}
+		compare = func(a, b *Node) int {
+			c := fn(a, b)
+			if desc {
+				c = -c
+			}
+			if c == 0 {
+				return defaultOrder(a, b)
+			}
+			return c
+		}
+	}
+
+	explicit := map[string]int{}
+	for i, name := range n.Params.Strings("order") {
+		explicit[name] = i + 1
+	}
+
+	sort.SliceStable(n.Children, func(i, j int) bool {
+		a, b := n.Children[i], n.Children[j]
+		ia, ib := explicit[a.Info.Name()], explicit[b.Info.Name()]
+		switch {
+		case ia > 0 && ib > 0:
+			return ia < ib
+		case ia > 0 || ib > 0:
+			return ia > 0
+		}
+		return compare(a, b) < 0
+	})
+	return nil
+}
+
+// naturalCompare compares the strings case-insensitively with the digit
+// runs compared by their numeric values, so that "2" goes before "10"
+func naturalCompare(a, b string) int {
+	la, lb := strings.ToLower(a), strings.ToLower(b)
+	for la != "" && lb != "" {
+		da, db := digitPrefix(la), digitPrefix(lb)
+		if da != "" && db != "" {
+			na, nb := strings.TrimLeft(da, "0"), strings.TrimLeft(db, "0")
+			if len(na) != len(nb) {
+				return len(na) - len(nb)
+			}
+			if c := strings.Compare(na, nb); c != 0 {
+				return c
+			}
+			la, lb = la[len(da):], lb[len(db):]
+			continue
+		}
+
+		if la[0] != lb[0] {
+			return int(la[0]) - int(lb[0])
+		}
+		la, lb = la[1:], lb[1:]
+	}
+
+	if c := len(la) - len(lb); c != 0 {
+		return c
+	}
+	return strings.Compare(a, b)
+}
+
+func digitPrefix(s string) string {
+	i := 0
+	for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+		i++
+	}
+	return s[:i]
+}
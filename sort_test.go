@@ -0,0 +1,64 @@
+package swgen
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNaturalCompare(t *testing.T) {
+	assert.True(t, naturalCompare("file2", "file10") < 0)
+	assert.True(t, naturalCompare("File1", "file2") < 0)
+	assert.True(t, naturalCompare("a", "a1") < 0)
+	assert.True(t, naturalCompare("v1.9", "v1.10") < 0)
+	assert.True(t, naturalCompare("007", "7") != 0)
+	assert.Equal(t, 0, naturalCompare("same", "same"))
+}
+
+func TestSortChildren(t *testing.T) {
+	names := func(n *Node) []string {
+		list := []string{}
+		for _, c := range n.Children {
+			list = append(list, c.Info.Name())
+		}
+		return list
+	}
+
+	cases := []struct {
+		config string
+		expect []string
+	}{
+		{"", []string{"sub", "a2.html", "a10.html", "b.html"}},
+		{"sort: weight\n", []string{"sub", "b.html", "a10.html", "a2.html"}},
+		{"sort: title\n", []string{"a10.html", "a2.html", "b.html", "sub"}},
+		{"sort: -date\n", []string{"a2.html", "b.html", "a10.html", "sub"}},
+		{"sort: date\norder: [b.html, sub]\n", []string{"b.html", "sub", "a10.html", "a2.html"}},
+	}
+
+	for _, c := range cases {
+		source := makeSite(t, map[string]string{
+			DirConfigFile:    c.config,
+			"a10.html":       "---\ntitle: Alpha\nweight: 2\ndate: 2019-01-01\n---\n",
+			"a2.html":        "---\ntitle: Beta\nweight: 3\ndate: 2019-03-01\n---\n",
+			"b.html":         "---\ntitle: Gamma\nweight: 1\ndate: 2019-02-01\n---\n",
+			"sub/.swdir.yml": "title: Zeta\ndate: 2018-01-01\n",
+		})
+
+		sw := Swgen{Source: source, Ignore: &dummyIgnore{}}
+		tree, err := sw.Scan(source)
+		if assert.NoError(t, err) {
+			assert.Equal(t, c.expect, names(tree), c.config)
+			assert.Nil(t, tree.Children[0].Prev)
+			assert.Equal(t, tree.Children[1], tree.Children[0].Next)
+			assert.Equal(t, tree.Children[2], tree.Children[3].Prev)
+		}
+		os.RemoveAll(source)
+	}
+
+	source := makeSite(t, map[string]string{DirConfigFile: "sort: size\n"})
+	defer os.RemoveAll(source)
+	sw := Swgen{Source: source, Ignore: &dummyIgnore{}}
+	_, err := sw.Scan(source)
+	assert.Error(t, err)
+}
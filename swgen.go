@@ -1,15 +1,22 @@
 package swgen
 
 import (
+	"bytes"
 	"fmt"
 	"html/template"
 	"io"
+	"io/ioutil"
 	"log"
 	"os"
 	"path/filepath"
 	"strings"
+	texttemplate "text/template"
 )
 
+// StateSuffix makes the default state directory from the output directory,
+// such as output.swstate for output, see Swgen.StateDir
+const StateSuffix = ".swstate"
+
 // Swgen is the main structure to scan source directory and render pages to target directory
 type Swgen struct {
 	Source   string
@@ -17,51 +24,177 @@ type Swgen struct {
 	URLRoot  string
 	Ignore   Ignore
 	Force    bool
+	Git      bool
 	Template *template.Template
+	// Templates is the layouts with the base layout and partials, it takes
+	// precedence over Template
+	Templates *Templates
+
+	// EditURL is the pattern to build the link to edit a page in the
+	// repository, such as https://git.example/repo/edit/main/{{.Rel}}
+	EditURL string
+	// PublishSource copies the raw source next to the rendered page
+	PublishSource bool
+
+	// Drafts includes the pages and directories with the "draft" param
+	Drafts bool
+	// Params is the site params available to templates as .Site.Params
+	Params map[string]interface{}
+	// Profile is the name of the active build profile
+	Profile string
+	// BaseURL is the scheme and host of the published site used by absURL,
+	// such as https://wiki.example
+	BaseURL string
+	// Funcs is the additional template functions, see FuncMap
+	Funcs template.FuncMap
+	// Theme is the theme directory, see Theme
+	Theme string
+	// Outputs is the output formats written besides the HTML pages keyed
+	// by the name, see OutputFormat
+	Outputs map[string]*OutputFormat
+	// Feeds writes the Atom and RSS feeds if it is set, see FeedConfig
+	Feeds *FeedConfig
+	// StateDir keeps the build state between the builds, such as the
+	// rendered pages and what they use, so that the unchanged pages are
+	// skipped. It is the output directory with the suffix StateSuffix if
+	// it is empty, and it is not part of the output to deploy.
+	StateDir string
+	// RelativeURLs rewrites the links of the rendered pages relative to
+	// the page, so that the output is browsable from the file system. The
+	// links of 404.html stay absolute, see renderNotFound.
+	RelativeURLs bool
+	// StrictTemplates makes a missing map key, such as an unset param, an
+	// error of the Templates rather than an empty value
+	StrictTemplates bool
+
+	gitInfo  map[string]*GitInfo
+	metadata *Metadata
+	editURL  *texttemplate.Template
+	site     *Site
+	theme    *Theme
+	builtin  *Templates
 }
 
 // Doc is the virtual page object to render
 type Doc struct {
 	Toc  template.HTML
 	Page template.HTML
+	Site *Site
 	*Node
+	// TocEntries is the headings of Toc, see Node.Toc
+	TocEntries []*TocEntry
 }
 
 // Run scans source directory and render pages to output directory
 func (sw *Swgen) Run() error {
-	if err := os.MkdirAll(sw.Target, os.ModePerm); err != nil {
+	for _, dir := range []string{sw.Target, sw.stateDir()} {
+		if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+			return err
+		}
+	}
+	if err := sw.removeLegacyState(); err != nil {
+		return err
+	}
+
+	site, err := sw.loadSite()
+	if err != nil {
 		return err
 	}
+	sw.site = site
 
 	tree, err := sw.Scan(sw.Source)
 	if err != nil {
 		return err
 	}
+	sw.site.home = tree
+	if err := sw.site.checkTree(sw.stateDir(), tree); err != nil {
+		return err
+	}
 
 	metadata := &Metadata{}
-	content := template.HTML("")
-	return sw.renderAll(tree, metadata, content)
+	sw.metadata = metadata
+	if err := sw.renderAll(tree, metadata); err != nil {
+		return err
+	}
+	sw.site.end()
+	if err := sw.pruneContent(tree); err != nil {
+		return err
+	}
+
+	if err := sw.renderAliases(tree); err != nil {
+		return err
+	}
+	if err := sw.renderFeeds(tree, metadata); err != nil {
+		return err
+	}
+	if err := sw.renderNotFound(tree, metadata); err != nil {
+		return err
+	}
+	if sw.Templates != nil {
+		if err := sw.Templates.copyAssets(sw.Target); err != nil {
+			return err
+		}
+	}
+	if err := sw.site.saveTree(sw.stateDir()); err != nil {
+		return err
+	}
+	return sw.site.saveUsers(sw.stateDir())
 }
 
-func (sw *Swgen) renderAll(n *Node, m *Metadata, c template.HTML) error {
-	dest := sw.MustGetTargetPath(n.path)
+// stateDir is the StateDir, or the output directory with StateSuffix
+func (sw *Swgen) stateDir() string {
+	if sw.StateDir != "" {
+		return sw.StateDir
+	}
+	return filepath.Clean(sw.Target) + StateSuffix
+}
 
-	if n.Info.IsDir() {
-		if err := os.MkdirAll(dest, os.ModePerm); err != nil {
+// isStateDir reports whether the path is the state directory, which is
+// skipped if it lives in the source directory
+func (sw *Swgen) isStateDir(path string) bool {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return false
+	}
+	state, err := filepath.Abs(sw.stateDir())
+	return err == nil && abs == state
+}
+
+// removeLegacyState deletes the build state kept in the output directory
+// by the older versions, so that it is not deployed
+func (sw *Swgen) removeLegacyState() error {
+	if sw.isStateDir(sw.Target) {
+		return nil
+	}
+	for _, name := range []string{dataUsersFile, pagesUsersFile, treeFile, aliasesFile, ContentDir} {
+		if err := os.RemoveAll(filepath.Join(sw.Target, name)); err != nil {
 			return err
 		}
+	}
+	return nil
+}
+
+func (sw *Swgen) renderAll(n *Node, m *Metadata) error {
+	dest := sw.TargetFile(n)
+
+	if n.Info.IsDir() {
+		if n.Index != nil {
+			if err := sw.publishSource(n.Index); err != nil {
+				return err
+			}
+		}
 
-		dest := fmt.Sprintf("%s/index.html", sw.MustGetTargetPath(n.path))
+		sw.site.begin(n)
 		html, err := n.RenderDir(m)
 		if err != nil {
 			return err
 		}
-		if err := sw.render(dest, n, c, html); err != nil {
+		if err := sw.render(dest, n, html); err != nil {
 			return err
 		}
 
 		for _, child := range n.Children {
-			err := sw.renderAll(child, m, c)
+			err := sw.renderAll(child, m)
 			if err != nil {
 				return err
 			}
@@ -71,45 +204,161 @@ func (sw *Swgen) renderAll(n *Node, m *Metadata, c template.HTML) error {
 	}
 
 	// regular files
-	suffix := filepath.Ext(dest)
-	if !(strings.EqualFold(suffix, "html") || strings.EqualFold(suffix, "htm")) {
-		dest = fmt.Sprintf("%s.html", dest)
+	if err := sw.publishSource(n); err != nil {
+		return err
 	}
 
-	// if the target exists and force flag is not enable, then skip the generate
-	destInfo, err := os.Stat(dest)
-	if err == nil && destInfo.ModTime().After(n.Info.ModTime()) && !sw.Force {
+	if sw.fresh(n, dest) && sw.outputsExist(n) {
 		log.Printf("skip existed file %s", dest)
 		return nil
 	}
 
-	html, err := n.Render(m)
+	// the content read by a listing in this build is reused, the data and
+	// pages it used are marked already. Otherwise it is rendered again
+	// rather than taken from the cache, so that they are marked.
+	if n.rendered {
+		sw.site.rendering = n
+		return sw.render(dest, n, n.content)
+	}
+	sw.site.begin(n)
+	html, err := n.renderPage(m)
 	if err != nil {
 		return err
 	}
+	if err := n.keepContent(html); err != nil {
+		return err
+	}
+
+	return sw.render(dest, n, html)
+}
+
+// fresh reports whether the output file of the node is up to date: it
+// exists and the force flag is not enabled, it is newer than the source,
+// the data files and pages the node used, and the tree it links is not
+// changed
+func (sw *Swgen) fresh(n *Node, output string) bool {
+	info, err := os.Stat(output)
+	if err != nil || sw.Force || sw.site == nil || sw.site.treeChanged {
+		return false
+	}
+	t := info.ModTime()
+	return t.After(n.ModTime()) && !sw.site.dataChanged(n, t) && !sw.site.pagesChanged(n, t)
+}
 
-	return sw.render(dest, n, c, html)
+// publishSource copies the raw source of the page next to its output if
+// PublishSource is enabled, see Node.SourceURL
+func (sw *Swgen) publishSource(n *Node) error {
+	if !sw.PublishSource {
+		return nil
+	}
+	return sw.copyTo(n.path, sw.MustGetTargetPath(n.path)+".txt")
 }
 
-func (sw *Swgen) render(dest string, n *Node, c, html template.HTML) error {
-	log.Printf("render %s to %s", n.path, dest)
-	fd, err := os.Create(dest)
+func (sw *Swgen) render(dest string, n *Node, html template.HTML) error {
+	tmpl, err := sw.lookupLayout(n)
 	if err != nil {
 		return err
 	}
-	defer fd.Close()
 
+	page, toc, entries := n.Toc(html)
 	doc := &Doc{
-		Toc:  c,
-		Page: html,
-		Node: n,
+		Toc:        toc,
+		Page:       page,
+		Site:       sw.site,
+		Node:       n,
+		TocEntries: entries,
+	}
+	if err := sw.write(dest, tmpl, doc, sw.RelativeURLs); err != nil {
+		return err
+	}
+	return sw.renderOutputs(n, doc)
+}
+
+// write executes the layout and writes the output file with the links made
+// relative to it if relative is set, nothing is left at dest if the
+// execution fails
+func (sw *Swgen) write(dest string, tmpl *template.Template, doc *Doc, relative bool) error {
+	log.Printf("render %s to %s", doc.path, dest)
+	buf := &bytes.Buffer{}
+	if err := tmpl.Execute(buf, doc); err != nil {
+		if err := os.Remove(dest); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return fmt.Errorf("render %s failed: %s", doc.path, doc.templateSet().explain(err))
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dest), os.ModePerm); err != nil {
+		return err
+	}
+	output := buf.Bytes()
+	if relative {
+		output = sw.relativize(output, dest)
+	}
+	return ioutil.WriteFile(dest, output, 0644)
+}
+
+// renderNotFound renders the special page 404.html with the home node if
+// the layout exists. The server shows it at any missing path, so its links
+// are kept absolute even with RelativeURLs.
+func (sw *Swgen) renderNotFound(tree *Node, m *Metadata) error {
+	if sw.Templates == nil {
+		return nil
+	}
+
+	tmpl := sw.Templates.Lookup("404")
+	if tmpl == nil {
+		return nil
+	}
+	return sw.write(filepath.Join(sw.Target, "404.html"), tmpl, &Doc{Site: sw.site, Node: tree}, false)
+}
+
+// layoutFallbacks is the layouts to try in order for each node kind when
+// the node does not name a layout
+var layoutFallbacks = map[string][]string{
+	"home": {"home", "dir", "page"},
+	"dir":  {"dir", "page"},
+	"page": {"page"},
+}
+
+// lookupLayout resolves the node's layout against the Templates, or the
+// named templates of Template, a template named "talk" is found by both
+// "talk" and "talk.html"
+func (sw *Swgen) lookupLayout(n *Node) (*template.Template, error) {
+	ts := n.templateSet()
+	lookup := func(name string) *template.Template {
+		if ts != nil {
+			return ts.Lookup(name)
+		}
+		if t := sw.Template.Lookup(name); t != nil {
+			return t
+		}
+		return sw.Template.Lookup(name + ".html")
+	}
+
+	if name := n.Layout(); name != "" {
+		if t := lookup(name); t != nil {
+			return t, nil
+		}
+		return nil, fmt.Errorf("layout %q of %s is not found", name, n.path)
+	}
+
+	for _, name := range layoutFallbacks[n.Kind()] {
+		if t := lookup(name); t != nil {
+			return t, nil
+		}
 	}
 
-	return sw.Template.Execute(fd, doc)
+	if sw.Template == nil {
+		return nil, fmt.Errorf("no layout for %s %s", n.Kind(), n.path)
+	}
+	return sw.Template, nil
 }
 
 func (sw *Swgen) copy(src string) error {
-	dest := sw.MustGetTargetPath(src)
+	return sw.copyTo(src, sw.MustGetTargetPath(src))
+}
+
+func (sw *Swgen) copyTo(src, dest string) error {
 	if err := os.MkdirAll(filepath.Dir(dest), os.ModePerm); err != nil {
 		return err
 	}
@@ -130,6 +379,29 @@ func (sw *Swgen) copy(src string) error {
 	return err
 }
 
+// TargetFile is the output file of the node, a directory is rendered to
+// its index.html and a page with a permalink pattern to the expanded path
+func (sw *Swgen) TargetFile(n *Node) string {
+	if n.target != "" {
+		return n.target
+	}
+
+	if n.Info.IsDir() {
+		return filepath.Join(sw.MustGetTargetPath(n.path), "index.html")
+	}
+	return sw.pageFile(n.path)
+}
+
+// pageFile is the output file of the page at the source path
+func (sw *Swgen) pageFile(path string) string {
+	dest := sw.MustGetTargetPath(path)
+	suffix := filepath.Ext(dest)
+	if !(strings.EqualFold(suffix, "html") || strings.EqualFold(suffix, "htm")) {
+		dest = fmt.Sprintf("%s.html", dest)
+	}
+	return dest
+}
+
 // MustGetRelPath get the relative path
 func (sw *Swgen) MustGetRelPath(path string) string {
 	rel, err := filepath.Rel(sw.Source, path)
@@ -157,6 +429,13 @@ func (sw *Swgen) Scan(root string) (*Node, error) {
 		return nil, fmt.Errorf("root %s must be a directory", root)
 	}
 
+	if sw.Git {
+		sw.gitInfo, err = LoadGitInfo(root)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	home := &Node{
 		Swgen:    sw,
 		Info:     info,
@@ -164,7 +443,19 @@ func (sw *Swgen) Scan(root string) (*Node, error) {
 		Children: []*Node{},
 	}
 
-	return sw.scan(root, info, home)
+	tree, err := sw.scan(root, info, home)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := sw.assignTargets(tree); err != nil {
+		return nil, err
+	}
+	if err := sw.loadDirTemplates(tree); err != nil {
+		return nil, err
+	}
+	sw.linkReadingOrder(tree)
+	return tree, nil
 }
 
 func (sw *Swgen) scan(path string, info os.FileInfo, home *Node) (*Node, error) {
@@ -174,52 +465,97 @@ func (sw *Swgen) scan(path string, info os.FileInfo, home *Node) (*Node, error)
 		path:     path,
 		Children: []*Node{},
 		Home:     home,
+		Git:      sw.gitInfo[sw.MustGetRelPath(path)],
 	}
 
 	if info.IsDir() {
-		dir, err := os.Open(path)
+		params, err := LoadDirConfig(filepath.Join(path, DirConfigFile))
 		if err != nil {
 			return nil, err
 		}
+		n.Params = params
+	} else if err := n.load(); err != nil {
+		return nil, err
+	}
 
-		children, err := dir.Readdir(0)
-		if err != nil {
-			return nil, err
+	// a draft is left out of the tree, the caller skips the nil node
+	if n.Params.Bool("draft") && !sw.Drafts && path != home.path {
+		log.Printf("skip draft %s", path)
+		return nil, nil
+	}
+
+	if !info.IsDir() {
+		return n, nil
+	}
+
+	dir, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer dir.Close()
+
+	children, err := dir.Readdir(0)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, child := range children {
+		if child.Name() == DirConfigFile {
+			continue
+		}
+		if child.Name() == TemplateDir && child.IsDir() {
+			n.templateDir = filepath.Join(path, child.Name())
+			continue
 		}
 
-		for _, child := range children {
-			path := filepath.Join(path, child.Name())
-			if sw.Ignore.Ignore(sw.MustGetRelPath(path)) {
-				log.Printf("ignore path %s", path)
-				continue
-			}
+		path := filepath.Join(path, child.Name())
+		if path == filepath.Join(sw.Source, DataDir) || path == filepath.Join(sw.Source, ConfigFile) {
+			continue
+		}
+		if sw.isStateDir(path) {
+			log.Printf("skip state directory %s", path)
+			continue
+		}
 
-			if !child.IsDir() {
-				ext := filepath.Ext(child.Name())
-				if _, ok := RenderFns[ext]; !ok {
-					sw.copy(path)
-					continue
-				}
-			}
+		if sw.Ignore.Ignore(sw.MustGetRelPath(path)) {
+			log.Printf("ignore path %s", path)
+			continue
+		}
 
-			subNode, err := sw.scan(path, child, home)
-			if err != nil {
-				return nil, err
+		if !child.IsDir() {
+			ext := filepath.Ext(child.Name())
+			if _, ok := RenderFns[ext]; !ok {
+				sw.copy(path)
+				n.Attachments = append(n.Attachments, &Attachment{Info: child, path: path, sw: sw})
+				continue
 			}
+		}
 
-			subNode.Up = n
-			n.Children = append(n.Children, subNode)
+		subNode, err := sw.scan(path, child, home)
+		if err != nil {
+			return nil, err
+		}
+		if subNode == nil {
+			continue
 		}
 
-		// update prev/next link
-		for i := 0; i < len(n.Children); i++ {
-			if i > 0 {
-				n.Children[i-1].Next = n.Children[i]
-			}
+		subNode.Up = n
+		n.Children = append(n.Children, subNode)
+	}
 
-			if i < len(n.Children)-1 {
-				n.Children[i+1].Prev = n.Children[i]
-			}
+	n.pickIndex()
+	if err := sortChildren(n); err != nil {
+		return nil, err
+	}
+
+	// update prev/next link
+	for i := 0; i < len(n.Children); i++ {
+		if i > 0 {
+			n.Children[i-1].Next = n.Children[i]
+		}
+
+		if i < len(n.Children)-1 {
+			n.Children[i+1].Prev = n.Children[i]
 		}
 	}
 
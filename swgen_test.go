@@ -2,8 +2,13 @@ package swgen
 
 import (
 	"html/template"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+
+	"github.com/stretchr/testify/assert"
 )
 
 type dummyIgnore struct{}
@@ -20,19 +25,196 @@ func (i *dummyIgnore) Ignore(path string) bool {
 	if strings.HasSuffix(path, "~") {
 		return true
 	}
-	
+
 	return false
 }
 
+// makeSite writes the files into a temporary directory and returns its path
+func makeSite(t *testing.T, files map[string]string) string {
+	dir, err := ioutil.TempDir("", "swgen")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+// readState reads the file of the build state, it is empty if missing
+func readState(sw *Swgen, name string) string {
+	content, _ := ioutil.ReadFile(filepath.Join(sw.stateDir(), name))
+	return string(content)
+}
+
 func TestGenerate(t *testing.T) {
+	source := makeSite(t, map[string]string{
+		"index.html":       "<p>index</p>",
+		"notes/hello.html": "<p>hello</p>",
+		"notes/hello.htm~": "backup",
+		"image.png":        "png",
+	})
+	defer os.RemoveAll(source)
+
 	tmpl := template.Must(template.New("page").Parse(`Page: {{.Page}}`))
 	sw := Swgen{
-		source:   ".",
-		target:   "testing",
-		ignore:   &dummyIgnore{},
-		template: tmpl,
+		Source:   source,
+		Target:   filepath.Join(source, "testing"),
+		Ignore:   &dummyIgnore{},
+		Template: tmpl,
 	}
 	if err := sw.Run(); err != nil {
 		t.Error(err)
 	}
 }
+
+func TestEditAndSourceURL(t *testing.T) {
+	source := makeSite(t, map[string]string{
+		"notes/hello.html": "<p>hello</p>",
+		"guide/README.md":  "<p>guide</p>",
+	})
+	defer os.RemoveAll(source)
+
+	markdown := RenderFns[".md"]
+	RenderFns[".md"] = RenderHTML
+	defer func() { RenderFns[".md"] = markdown }()
+
+	tmpl := template.Must(template.New("page").Parse(`{{.EditURL}} {{.SourceURL}}`))
+	sw := Swgen{
+		Source:        source,
+		Target:        filepath.Join(source, "testing"),
+		URLRoot:       "wiki",
+		Ignore:        &dummyIgnore{},
+		Template:      tmpl,
+		EditURL:       "https://git.example/repo/edit/main/{{.Rel}}",
+		PublishSource: true,
+	}
+	if err := sw.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	page, err := ioutil.ReadFile(filepath.Join(sw.Target, "notes/hello.html.html"))
+	assert.NoError(t, err)
+	assert.Equal(t, "https://git.example/repo/edit/main/notes/hello.html /wiki/notes/hello.html.txt", string(page))
+
+	raw, err := ioutil.ReadFile(filepath.Join(sw.Target, "notes/hello.html.txt"))
+	assert.NoError(t, err)
+	assert.Equal(t, "<p>hello</p>", string(raw))
+
+	index, err := ioutil.ReadFile(filepath.Join(sw.Target, "notes/index.html"))
+	assert.NoError(t, err)
+	assert.Equal(t, " ", string(index))
+
+	// a directory links the source of its index file
+	index, err = ioutil.ReadFile(filepath.Join(sw.Target, "guide/index.html"))
+	assert.NoError(t, err)
+	assert.Equal(t, "https://git.example/repo/edit/main/guide/README.md /wiki/guide/README.md.txt", string(index))
+	raw, err = ioutil.ReadFile(filepath.Join(sw.Target, "guide/README.md.txt"))
+	assert.NoError(t, err)
+	assert.Equal(t, "<p>guide</p>", string(raw))
+}
+
+func TestLayout(t *testing.T) {
+	source := makeSite(t, map[string]string{
+		"index.html":       "<p>index</p>",
+		"talks/.swdir.yml": "page_layout: talk\n",
+		"talks/one.html":   "<p>one</p>",
+		"talks/two.html":   "---\nlayout: page\n---\n<p>two</p>",
+		"notes/.swdir.yml": "layout: special\n",
+		"notes/note.html":  "<p>note</p>",
+	})
+	defer os.RemoveAll(source)
+
+	tmpl := template.Must(template.New("page.html").Parse(`page {{.Page}}`))
+	template.Must(tmpl.New("talk.html").Parse(`talk {{.Page}}`))
+	template.Must(tmpl.New("special").Parse(`special`))
+	template.Must(tmpl.New("home.html").Parse(`home`))
+	sw := Swgen{
+		Source:   source,
+		Target:   filepath.Join(source, "testing"),
+		Ignore:   &dummyIgnore{},
+		Template: tmpl,
+	}
+	assert.NoError(t, sw.Run())
+
+	for path, expect := range map[string]string{
+		"index.html":           "home",
+		"index.html.html":      "page <p>index</p>",
+		"talks/index.html":     "page ",
+		"talks/one.html.html":  "talk <p>one</p>",
+		"talks/two.html.html":  "page <p>two</p>",
+		"notes/index.html":     "special",
+		"notes/note.html.html": "page <p>note</p>",
+	} {
+		content, err := ioutil.ReadFile(filepath.Join(sw.Target, path))
+		if assert.NoError(t, err, path) {
+			assert.True(t, strings.HasPrefix(string(content), expect), path)
+		}
+	}
+	_, err := os.Stat(filepath.Join(sw.Target, "notes", DirConfigFile))
+	assert.True(t, os.IsNotExist(err))
+
+	bad := filepath.Join(source, "notes", "bad.html")
+	assert.NoError(t, ioutil.WriteFile(bad, []byte("---\nlayout: nothing\n---\n"), 0644))
+	err = sw.Run()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), `layout "nothing"`)
+		assert.Contains(t, err.Error(), bad)
+	}
+}
+
+func TestDirIndex(t *testing.T) {
+	markdown := RenderFns[".md"]
+	RenderFns[".md"] = RenderHTML
+	defer func() { RenderFns[".md"] = markdown }()
+
+	source := makeSite(t, map[string]string{
+		"guide/README.md":   "---\ntitle: The Guide\n---\n<p>readme</p>",
+		"guide/_index.md":   "---\nlayout: post\naliases: [old/]\nauthor: ann\n---\n<p>index</p>",
+		"guide/.swdir.yml":  "description: How to do things\n",
+		"guide/a.html":      "<p>a</p>",
+		"plain/README.md":   "<p>plain</p>",
+		"plain/.swdir.yml":  "listing: none\ntitle: Plain\n",
+		"plain/hidden.html": "<p>hidden</p>",
+	})
+	defer os.RemoveAll(source)
+
+	sw := Swgen{
+		Source:   source,
+		Target:   filepath.Join(source, "testing"),
+		Ignore:   &dummyIgnore{},
+		Template: template.Must(template.New("page").Parse(`{{.Page}}{{define "post"}}post {{.Page}}{{end}}`)),
+	}
+	tree, err := sw.Scan(source)
+	assert.NoError(t, err)
+	guide := tree.Children[0]
+	assert.Equal(t, "_index.md", guide.Index.Info.Name())
+	assert.Len(t, guide.Children, 2)
+	assert.Equal(t, "README.md", guide.Children[1].Info.Name())
+	assert.Equal(t, "ann", guide.Params.String("author"))
+	assert.Equal(t, "post", guide.Layout())
+	assert.Equal(t, []string{"old/"}, guide.Aliases())
+
+	assert.NoError(t, sw.Run())
+	index, err := ioutil.ReadFile(filepath.Join(sw.Target, "guide/index.html"))
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(index), "post <p>index</p>"))
+	assert.Contains(t, string(index), "<p>How to do things</p>")
+	assert.Contains(t, string(index), `<a href="/guide/a.html.html">a</a>`)
+	assert.NotContains(t, string(index), `_index.md`)
+	stub, err := ioutil.ReadFile(filepath.Join(sw.Target, "old/index.html"))
+	assert.NoError(t, err)
+	assert.Contains(t, string(stub), `url=/guide/"`)
+
+	index, err = ioutil.ReadFile(filepath.Join(sw.Target, "plain/index.html"))
+	assert.NoError(t, err)
+	assert.Equal(t, "<p>plain</p>", string(index))
+	assert.Equal(t, "Plain", tree.Children[1].Title())
+}
@@ -0,0 +1,557 @@
+package swgen
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"html/template"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	texttemplate "text/template"
+	"text/template/parse"
+)
+
+const (
+	// TemplateDir is the template directory of the site, or of a
+	// directory overriding the templates for its subtree
+	TemplateDir = ".template"
+	// BaseLayout is the layout extended by the others
+	BaseLayout = "baseof.html"
+	// PartialsDir is the directory of the partials in a template directory
+	PartialsDir = "partials"
+)
+
+// Templates is the layouts loaded from the template directories on top of
+// the built-in theme and the configured Theme, a file in a later directory
+// replaces the one of the same name in the earlier ones.
+//
+// The lookup rules are:
+//
+//   - baseof.html is the base layout. A layout whose body only has
+//     {{define}} blocks extends it, the blocks replace the {{block}}s of
+//     the base. A layout with its own body is used as is.
+//   - every other top level .html file is a layout named after the file,
+//     page.html is found by both "page" and "page.html". The nodes use
+//     "page", "dir" and "home" unless they name another layout, and the
+//     special page 404.html is rendered if it exists.
+//   - partials/<name>.html is a partial shared by all the layouts, it is
+//     called by {{partial "<name>" .}} or {{template "partials/<name>.html" .}}.
+//   - shortcodes/<name>.html is a shortcode, see Shortcode, and
+//     listings/<name>.html is a directory listing, see Listing. They may
+//     call the partials as well.
+//   - outputs/<file> of any extension is a text template of an output
+//     format, see OutputFormat.
+//
+// A template name defined by more than one file is reported as a
+// collision, except a block of the base layout redefined by a layout.
+//
+// A directory below the source directory may have its own .template, its
+// files go on top of the ones of the directory above for the nodes in it.
+type Templates struct {
+	funcs   template.FuncMap
+	files   map[string]*templateFile
+	layouts map[string]*template.Template
+
+	// assets is the static files to copy keyed by the output path
+	assets map[string]*templateFile
+	// shortcodes is the shortcode templates keyed by the name
+	shortcodes map[string]*template.Template
+	// listings is the directory listing templates keyed by the name
+	listings map[string]*template.Template
+	// outputs is the text templates of the output formats keyed by the
+	// file name
+	outputs map[string]*texttemplate.Template
+	// strict makes a missing map key an execution error
+	strict bool
+}
+
+type templateFile struct {
+	name string
+	path string
+	text string
+}
+
+// LoadTemplates loads the layouts and partials of the template directories,
+// a missing directory is skipped. The configured Theme goes below the
+// directories and its params fill in the missing site params.
+func (sw *Swgen) LoadTemplates(dirs ...string) (*Templates, error) {
+	ts := &Templates{
+		funcs:  sw.FuncMap(),
+		files:  map[string]*templateFile{},
+		assets: map[string]*templateFile{},
+		strict: sw.StrictTemplates,
+	}
+
+	for name, text := range defaultLayouts {
+		ts.files[name] = &templateFile{name: name, path: "builtin/" + name, text: text}
+	}
+	for name, text := range defaultAssets {
+		name = filepath.Join(AssetsDir, name)
+		ts.assets[name] = &templateFile{name: name, path: "builtin/" + name, text: text}
+	}
+
+	if sw.Theme != "" {
+		theme, err := sw.LoadTheme(sw.Theme)
+		if err != nil {
+			return nil, err
+		}
+		if err := ts.addTheme(theme); err != nil {
+			return nil, err
+		}
+
+		if len(theme.Params) > 0 && sw.Params == nil {
+			sw.Params = map[string]interface{}{}
+		}
+		for k, v := range theme.Params {
+			if _, ok := sw.Params[k]; !ok {
+				sw.Params[k] = v
+			}
+		}
+		sw.theme = theme
+	}
+
+	for _, dir := range dirs {
+		if err := ts.addDir(dir); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := ts.compile(); err != nil {
+		return nil, err
+	}
+	return ts, nil
+}
+
+// withDir is a copy of the Templates with the files of the directory on
+// top, the static assets are shared
+func (ts *Templates) withDir(dir string) (*Templates, error) {
+	layered := &Templates{
+		funcs:  ts.funcs,
+		files:  map[string]*templateFile{},
+		assets: ts.assets,
+		strict: ts.strict,
+	}
+	for name, f := range ts.files {
+		layered.files[name] = f
+	}
+
+	if err := layered.addDir(dir); err != nil {
+		return nil, err
+	}
+	if err := layered.compile(); err != nil {
+		return nil, err
+	}
+	return layered, nil
+}
+
+func (ts *Templates) addDir(dir string) error {
+	for _, sub := range []string{"", PartialsDir, ShortcodesDir, ListingsDir, OutputsDir} {
+		files, err := ioutil.ReadDir(filepath.Join(dir, sub))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		for _, f := range files {
+			if f.IsDir() || (filepath.Ext(f.Name()) != ".html" && sub != OutputsDir) {
+				continue
+			}
+
+			path := filepath.Join(dir, sub, f.Name())
+			text, err := ioutil.ReadFile(path)
+			if err != nil {
+				return err
+			}
+
+			name := filepath.ToSlash(filepath.Join(sub, f.Name()))
+			ts.files[name] = &templateFile{name: name, path: path, text: string(text)}
+		}
+	}
+	return nil
+}
+
+// fingerprint is a hash of the names and the texts of the template files,
+// it changes when any of them is edited, added or removed
+func (ts *Templates) fingerprint() string {
+	if ts == nil {
+		return ""
+	}
+	names := []string{}
+	for name := range ts.files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	h := sha1.New()
+	for _, name := range names {
+		fmt.Fprintf(h, "%s\x00%s\x00", name, ts.files[name].text)
+	}
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
+
+// copyAssets writes the static files into the output directory, the
+// built-in ones come from the text and the others from the path
+func (ts *Templates) copyAssets(target string) error {
+	for name, f := range ts.assets {
+		text := []byte(f.text)
+		if f.text == "" {
+			var err error
+			if text, err = ioutil.ReadFile(f.path); err != nil {
+				return err
+			}
+		}
+
+		dest := filepath.Join(target, name)
+		if err := os.MkdirAll(filepath.Dir(dest), os.ModePerm); err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(dest, text, 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Lookup returns the layout by its name with or without the .html suffix,
+// it is nil if the layout does not exist
+func (ts *Templates) Lookup(name string) *template.Template {
+	if t, ok := ts.layouts[name]; ok {
+		return t
+	}
+	return ts.layouts[name+".html"]
+}
+
+// output returns the text template of an output format by the file name,
+// it is nil if the template does not exist
+func (ts *Templates) output(name string) *texttemplate.Template {
+	if ts == nil {
+		return nil
+	}
+	return ts.outputs[name]
+}
+
+// compile parses each layout together with the base layout and partials,
+// and checks the HTML escaping of all of them
+func (ts *Templates) compile() error {
+	ts.outputs = map[string]*texttemplate.Template{}
+	defines := map[string]map[string]bool{}
+	for name, f := range ts.files {
+		if strings.HasPrefix(name, OutputsDir+"/") {
+			t, err := texttemplate.New(name).Funcs(texttemplate.FuncMap(ts.funcs)).Parse(f.text)
+			if err != nil {
+				return ts.explain(fmt.Errorf("parse template %s failed: %s", f.path, err))
+			}
+			if ts.strict {
+				t.Option("missingkey=error")
+			}
+			ts.outputs[path.Base(name)] = t
+			continue
+		}
+
+		names, err := ts.definedNames(f)
+		if err != nil {
+			return err
+		}
+		defines[name] = names
+	}
+
+	partials := []*templateFile{}
+	for name, f := range ts.files {
+		if strings.HasPrefix(name, PartialsDir+"/") {
+			partials = append(partials, f)
+		}
+	}
+	sort.Slice(partials, func(i, j int) bool { return partials[i].name < partials[j].name })
+
+	shared := partials
+	if base, ok := ts.files[BaseLayout]; ok {
+		shared = append([]*templateFile{base}, partials...)
+	}
+
+	blocks := defines[BaseLayout]
+	ts.layouts = map[string]*template.Template{}
+	ts.shortcodes = map[string]*template.Template{}
+	ts.listings = map[string]*template.Template{}
+	fragments := map[string]map[string]*template.Template{
+		ShortcodesDir: ts.shortcodes,
+		ListingsDir:   ts.listings,
+	}
+	for name, f := range ts.files {
+		if name == BaseLayout || strings.HasPrefix(name, PartialsDir+"/") || strings.HasPrefix(name, OutputsDir+"/") {
+			continue
+		}
+
+		if fragment, ok := fragments[path.Dir(name)]; ok {
+			t, err := ts.parse(f, partials)
+			if err != nil {
+				return err
+			}
+			if err := ts.validate(t); err != nil {
+				return err
+			}
+			fragment[strings.TrimSuffix(path.Base(name), ".html")] = t
+			continue
+		}
+
+		// report the names defined by more than one file of the set
+		owners := map[string]string{}
+		for _, file := range append(shared, f) {
+			for define := range defines[file.name] {
+				if file == f && blocks[define] {
+					continue
+				}
+				if owner, ok := owners[define]; ok {
+					return fmt.Errorf("template %q is defined by both %s and %s", define, owner, file.path)
+				}
+				owners[define] = file.path
+			}
+		}
+
+		t, err := ts.parse(f, shared)
+		if err != nil {
+			return err
+		}
+		if err := ts.validate(t); err != nil {
+			return err
+		}
+		ts.layouts[name] = t
+	}
+	return nil
+}
+
+// parse parses the layout in a new set, the returned template is the base
+// layout if the layout extends it
+func (ts *Templates) parse(f *templateFile, shared []*templateFile) (*template.Template, error) {
+	var set *template.Template
+	funcs := template.FuncMap{
+		"partial": func(name string, data interface{}) (template.HTML, error) {
+			return executePartial(set, name, data)
+		},
+	}
+
+	set = template.New("").Funcs(ts.funcs).Funcs(funcs)
+	if ts.strict {
+		set.Option("missingkey=error")
+	}
+	for _, file := range append(shared, f) {
+		if _, err := set.New(file.name).Parse(file.text); err != nil {
+			return nil, ts.explain(fmt.Errorf("parse template %s failed: %s", file.path, err))
+		}
+	}
+
+	layout := set.Lookup(f.name)
+	if base := set.Lookup(BaseLayout); base != nil && (layout.Tree == nil || parse.IsEmptyTree(layout.Tree.Root)) {
+		return base, nil
+	}
+	return layout, nil
+}
+
+// definedNames parses the file alone and returns the template names it
+// defines besides itself
+func (ts *Templates) definedNames(f *templateFile) (map[string]bool, error) {
+	funcs := template.FuncMap{"partial": func(string, interface{}) template.HTML { return "" }}
+	t, err := template.New(f.name).Funcs(ts.funcs).Funcs(funcs).Parse(f.text)
+	if err != nil {
+		return nil, ts.explain(fmt.Errorf("parse template %s failed: %s", f.path, err))
+	}
+
+	names := map[string]bool{}
+	for _, define := range t.Templates() {
+		if define.Name() != f.name {
+			names[define.Name()] = true
+		}
+	}
+	return names, nil
+}
+
+// validate checks that the templates and the partials called by name in
+// the set are defined, the functions are checked by the parser. Nothing is
+// executed, the errors of the data and the escaping show up on rendering.
+func (ts *Templates) validate(set *template.Template) error {
+	for _, t := range set.Templates() {
+		if t.Tree == nil || t.Tree.Root == nil {
+			continue
+		}
+		if err := checkRefs(set, t.Tree, t.Tree.Root); err != nil {
+			return ts.explain(fmt.Errorf("check template %s failed: %s", t.Name(), err))
+		}
+	}
+	return nil
+}
+
+// checkRefs walks the parse tree for the {{template}} actions and the
+// partial calls with a constant name
+func checkRefs(set *template.Template, tree *parse.Tree, node parse.Node) error {
+	missing := func(n parse.Node, kind, name string) error {
+		location, _ := tree.ErrorContext(n)
+		return fmt.Errorf("template: %s: %s %q is not defined", location, kind, name)
+	}
+
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return nil
+		}
+		for _, c := range n.Nodes {
+			if err := checkRefs(set, tree, c); err != nil {
+				return err
+			}
+		}
+	case *parse.ActionNode:
+		return checkRefs(set, tree, n.Pipe)
+	case *parse.IfNode:
+		return checkBranch(set, tree, &n.BranchNode)
+	case *parse.RangeNode:
+		return checkBranch(set, tree, &n.BranchNode)
+	case *parse.WithNode:
+		return checkBranch(set, tree, &n.BranchNode)
+	case *parse.TemplateNode:
+		if set.Lookup(n.Name) == nil {
+			return missing(n, "template", n.Name)
+		}
+		return checkRefs(set, tree, n.Pipe)
+	case *parse.PipeNode:
+		if n == nil {
+			return nil
+		}
+		for _, cmd := range n.Cmds {
+			if err := checkRefs(set, tree, cmd); err != nil {
+				return err
+			}
+		}
+	case *parse.CommandNode:
+		if len(n.Args) > 1 {
+			fn, isIdent := n.Args[0].(*parse.IdentifierNode)
+			name, isString := n.Args[1].(*parse.StringNode)
+			if isIdent && isString && fn.Ident == "partial" {
+				partial := strings.TrimPrefix(name.Text, PartialsDir+"/")
+				if filepath.Ext(partial) != ".html" {
+					partial += ".html"
+				}
+				if set.Lookup(PartialsDir+"/"+partial) == nil {
+					return missing(n, "partial", name.Text)
+				}
+			}
+		}
+		for _, arg := range n.Args {
+			if err := checkRefs(set, tree, arg); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func checkBranch(set *template.Template, tree *parse.Tree, n *parse.BranchNode) error {
+	for _, c := range []parse.Node{n.Pipe, n.List, n.ElseList} {
+		if err := checkRefs(set, tree, c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// templateLine matches the template name and line in the errors of the
+// template packages, such as "template: page.html:3:"
+var templateLine = regexp.MustCompile(`template: ?([^:\s]+):(\d+)`)
+
+// explain appends the file and the source lines around the line reported
+// by the template error
+func (ts *Templates) explain(err error) error {
+	if ts == nil {
+		return err
+	}
+	m := templateLine.FindStringSubmatch(err.Error())
+	if m == nil {
+		return err
+	}
+	f, ok := ts.files[m[1]]
+	if !ok {
+		return err
+	}
+
+	line, _ := strconv.Atoi(m[2])
+	return fmt.Errorf("%s\n%s:%d\n%s", err, f.path, line, snippet(f.text, line))
+}
+
+// snippet is the line of the text with the ones before and after it, each
+// prefixed by its number and the line itself marked by ">"
+func snippet(text string, line int) string {
+	lines := strings.Split(text, "\n")
+	sb := &strings.Builder{}
+	for i := line - 1; i <= line+1; i++ {
+		if i < 1 || i > len(lines) {
+			continue
+		}
+		marker := " "
+		if i == line {
+			marker = ">"
+		}
+		fmt.Fprintf(sb, "%s %4d | %s\n", marker, i, lines[i-1])
+	}
+	return strings.TrimSuffix(sb.String(), "\n")
+}
+
+// executePartial runs partials/<name>.html of the set, the name may come
+// with the partials/ prefix or the .html suffix
+func executePartial(set *template.Template, name string, data interface{}) (template.HTML, error) {
+	name = strings.TrimPrefix(name, PartialsDir+"/")
+	if filepath.Ext(name) != ".html" {
+		name += ".html"
+	}
+
+	partial := set.Lookup(PartialsDir + "/" + name)
+	if partial == nil {
+		return "", fmt.Errorf("partial %s is not found", name)
+	}
+
+	sb := &strings.Builder{}
+	if err := partial.Execute(sb, data); err != nil {
+		return "", err
+	}
+	return template.HTML(sb.String()), nil
+}
+
+// templateSet is the Templates of the nearest directory with its own
+// .template, or the site Templates
+func (n *Node) templateSet() *Templates {
+	for up := n; up != nil; up = up.Up {
+		if up.templates != nil {
+			return up.templates
+		}
+	}
+	return n.Templates
+}
+
+// loadDirTemplates layers the .template of each directory below the source
+// directory on the Templates of the directory above. The one of the source
+// directory is the site Templates loaded by the caller.
+func (sw *Swgen) loadDirTemplates(tree *Node) error {
+	return tree.Walk(func(n *Node) error {
+		if n.templateDir == "" || n.Up == nil {
+			return nil
+		}
+
+		base := n.Up.templateSet()
+		if base == nil {
+			var err error
+			if base, err = sw.builtinTemplates(); err != nil {
+				return err
+			}
+		}
+
+		ts, err := base.withDir(n.templateDir)
+		if err != nil {
+			return fmt.Errorf("load templates of %s failed: %s", n.path, err)
+		}
+		n.templates = ts
+		return nil
+	})
+}
@@ -0,0 +1,248 @@
+package swgen
+
+import (
+	"html/template"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTemplates(t *testing.T) {
+	source := makeSite(t, map[string]string{
+		".template/baseof.html":       `<html>{{partial "nav" .}}<main>{{block "main" .}}default{{end}}</main></html>`,
+		".template/page.html":         `{{define "main"}}page {{.Page}}{{end}}`,
+		".template/dir.html":          `{{define "main"}}dir {{.Title}}{{end}}`,
+		".template/standalone.html":   `standalone {{template "partials/nav.html" .}}`,
+		".template/404.html":          `{{define "main"}}not found{{end}}`,
+		".template/partials/nav.html": `<nav>{{.Title | slugify}}</nav>`,
+		"notes/a.html":                "<p>a</p>",
+		"notes/b.html":                "---\nlayout: standalone\n---\n",
+	})
+	defer os.RemoveAll(source)
+
+	sw := &Swgen{
+		Source: source,
+		Target: filepath.Join(source, "testing"),
+		Ignore: &dummyIgnore{},
+	}
+	var err error
+	sw.Templates, err = sw.LoadTemplates(filepath.Join(source, ".template"))
+	assert.NoError(t, err)
+	assert.NotNil(t, sw.Templates.Lookup("page"))
+	assert.NotNil(t, sw.Templates.Lookup("page.html"))
+	assert.Nil(t, sw.Templates.Lookup("missing"))
+	assert.Nil(t, sw.Templates.Lookup("baseof"))
+
+	assert.NoError(t, sw.Run())
+	for file, expect := range map[string]string{
+		"notes/a.html.html": "<html><nav>a</nav><main>page <p>a</p></main></html>",
+		"notes/b.html.html": "standalone <nav>b</nav>",
+		"notes/index.html":  "<html><nav>notes</nav><main>dir notes</main></html>",
+		"404.html":          "<main>not found</main>",
+	} {
+		content, err := ioutil.ReadFile(filepath.Join(sw.Target, file))
+		if assert.NoError(t, err, file) {
+			assert.Contains(t, string(content), expect, file)
+		}
+	}
+}
+
+func TestTemplatesOverride(t *testing.T) {
+	dir := makeSite(t, map[string]string{
+		"theme/page.html":          `theme page`,
+		"theme/dir.html":           `theme dir`,
+		"site/page.html":           `site page`,
+		"collide/partials/a.html":  `{{define "header"}}a{{end}}`,
+		"collide/partials/b.html":  `{{define "header"}}b{{end}}`,
+		"block/baseof.html":        `{{block "main" .}}{{end}}`,
+		"block/partials/main.html": `{{define "main"}}partial{{end}}`,
+	})
+	defer os.RemoveAll(dir)
+
+	sw := &Swgen{}
+	ts, err := sw.LoadTemplates(filepath.Join(dir, "theme"), filepath.Join(dir, "site"), filepath.Join(dir, "missing"))
+	assert.NoError(t, err)
+
+	execute := func(name string) string {
+		sb := &strings.Builder{}
+		assert.NoError(t, ts.Lookup(name).Execute(sb, nil))
+		return sb.String()
+	}
+	assert.Equal(t, "site page", execute("page"))
+	assert.Equal(t, "theme dir", execute("dir"))
+
+	// the fingerprint follows the texts of the files
+	again, err := sw.LoadTemplates(filepath.Join(dir, "theme"), filepath.Join(dir, "site"))
+	assert.NoError(t, err)
+	assert.Equal(t, ts.fingerprint(), again.fingerprint())
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "site/page.html"), []byte(`edited page`), 0644))
+	edited, err := sw.LoadTemplates(filepath.Join(dir, "theme"), filepath.Join(dir, "site"))
+	assert.NoError(t, err)
+	assert.NotEqual(t, ts.fingerprint(), edited.fingerprint())
+
+	_, err = sw.LoadTemplates(filepath.Join(dir, "collide"), filepath.Join(dir, "site"))
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), `template "header" is defined by both`)
+	}
+
+	_, err = sw.LoadTemplates(filepath.Join(dir, "block"), filepath.Join(dir, "site"))
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), `template "main" is defined by both`)
+	}
+}
+
+func TestDefaultTheme(t *testing.T) {
+	source := makeSite(t, map[string]string{
+		".template/partials/footer.html": `custom footer`,
+		"notes/a.html":                   "---\ntitle: Note A\n---\n<p>a</p>",
+		"notes/b.html":                   "<p>b</p>",
+	})
+	defer os.RemoveAll(source)
+
+	sw := &Swgen{
+		Source: source,
+		Target: filepath.Join(source, "testing"),
+		Ignore: &dummyIgnore{},
+		Params: map[string]interface{}{"title": "My Wiki"},
+	}
+	var err error
+	sw.Templates, err = sw.LoadTemplates(filepath.Join(source, ".template"))
+	assert.NoError(t, err)
+	assert.NoError(t, sw.Run())
+
+	read := func(name string) string {
+		content, err := ioutil.ReadFile(filepath.Join(sw.Target, name))
+		assert.NoError(t, err, name)
+		return string(content)
+	}
+
+	page := read("notes/a.html.html")
+	assert.Contains(t, page, "<title>Note A - My Wiki</title>")
+	assert.Contains(t, page, `<link rel="stylesheet" href="/_swgen/swgen.css" />`)
+	assert.Contains(t, page, `<h1 class="title">Note A</h1>`)
+	assert.Contains(t, page, `<a class="next" href="/notes/b.html.html">b &rarr;</a>`)
+	assert.Contains(t, page, `<a class="up" href="/notes/">&uarr; notes</a>`)
+	assert.Contains(t, page, "custom footer")
+
+	assert.Contains(t, read("index.html"), "<title>My Wiki</title>")
+	assert.Contains(t, read("notes/index.html"), `<section class="dir">`)
+	assert.Contains(t, read(filepath.Join(AssetsDir, "swgen.css")), "prefers-color-scheme: dark")
+}
+
+func TestTemplateErrors(t *testing.T) {
+	dir := makeSite(t, map[string]string{
+		"syntax/page.html":  "<p>\n{{.Title}\n</p>",
+		"missing/page.html": "<p>\n{{if .Title}}{{template \"nope\" .}}{{end}}\n</p>",
+		"partial/page.html": `{{partial "nope" .}}`,
+		"escape/page.html":  `<a href="{{touch}}{{.Title}}>`,
+		"execute/page.html": "<p>\n{{.Nope}}\n</p>",
+		"strict/page.html":  `{{index .Params "summary"}}{{.Params.summary}}`,
+	})
+	defer os.RemoveAll(dir)
+
+	sw := &Swgen{}
+	_, err := sw.LoadTemplates(filepath.Join(dir, "syntax"))
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), filepath.Join(dir, "syntax/page.html")+":2\n")
+		assert.Contains(t, err.Error(), ">    2 | {{.Title}")
+		assert.Contains(t, err.Error(), "     3 | </p>")
+	}
+
+	_, err = sw.LoadTemplates(filepath.Join(dir, "missing"))
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), `template "nope" is not defined`)
+		assert.Contains(t, err.Error(), filepath.Join(dir, "missing/page.html")+":2\n")
+	}
+	_, err = sw.LoadTemplates(filepath.Join(dir, "partial"))
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), `partial "nope" is not defined`)
+	}
+
+	// the templates are not executed on loading
+	touched := 0
+	sw.Funcs = template.FuncMap{"touch": func() string { touched++; return "" }}
+	_, err = sw.LoadTemplates(filepath.Join(dir, "escape"))
+	assert.NoError(t, err)
+	assert.Equal(t, 0, touched)
+	sw.Funcs = nil
+
+	source := makeSite(t, map[string]string{"a.html": "<p>a</p>"})
+	defer os.RemoveAll(source)
+	sw = &Swgen{Source: source, Target: filepath.Join(source, "testing"), Ignore: &dummyIgnore{}, Force: true}
+	sw.Templates, err = sw.LoadTemplates(filepath.Join(dir, "execute"))
+	assert.NoError(t, err)
+
+	// the output of the failed render is removed
+	stale := filepath.Join(sw.Target, "a.html.html")
+	assert.NoError(t, os.MkdirAll(sw.Target, os.ModePerm))
+	assert.NoError(t, ioutil.WriteFile(stale, []byte("stale"), 0644))
+	err = sw.Run()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "render "+filepath.Join(source, "a.html")+" failed")
+		assert.Contains(t, err.Error(), ">    2 | {{.Nope}}")
+	}
+	_, err = os.Stat(stale)
+	assert.True(t, os.IsNotExist(err))
+
+	sw.Templates, err = sw.LoadTemplates(filepath.Join(dir, "strict"))
+	assert.NoError(t, err)
+	assert.NoError(t, sw.Run())
+
+	sw.StrictTemplates = true
+	sw.Templates, err = sw.LoadTemplates(filepath.Join(dir, "strict"))
+	assert.NoError(t, err)
+	err = sw.Run()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), `map has no entry for key "summary"`)
+	}
+}
+
+func TestDirTemplates(t *testing.T) {
+	source := makeSite(t, map[string]string{
+		".template/page.html":                     `site {{.Page}}`,
+		".template/dir.html":                      `site dir`,
+		"talks/.template/page.html":               `talk {{.Page}}`,
+		"talks/.template/shortcodes/slide.html":   `<section>{{.Inner}}</section>`,
+		"talks/one.html":                          `{{< slide >}}one{{< /slide >}}`,
+		"talks/2019/.template/partials/note.html": `2019`,
+		"talks/2019/.template/page.html":          `{{partial "note" .}} {{.Page}}`,
+		"talks/2019/two.html":                     `two`,
+		"talks/2019/.template/dir.html":           `{{partial "note" .}} dir`,
+		"notes/a.html":                            `a`,
+	})
+	defer os.RemoveAll(source)
+
+	sw := &Swgen{Source: source, Target: filepath.Join(source, "testing"), Ignore: &dummyIgnore{}}
+	var err error
+	sw.Templates, err = sw.LoadTemplates(filepath.Join(source, TemplateDir))
+	assert.NoError(t, err)
+	tree, err := sw.Scan(source)
+	assert.NoError(t, err)
+	assert.NotContains(t, tree.String(), TemplateDir)
+
+	assert.NoError(t, sw.Run())
+	for file, expect := range map[string]string{
+		"notes/a.html.html":        "site a",
+		"talks/index.html":         "site dir",
+		"talks/one.html.html":      "talk <section>one</section>",
+		"talks/2019/two.html.html": "2019 two",
+		"talks/2019/index.html":    "2019 dir",
+	} {
+		content, err := ioutil.ReadFile(filepath.Join(sw.Target, file))
+		if assert.NoError(t, err, file) {
+			assert.Equal(t, expect, string(content), file)
+		}
+	}
+	_, err = os.Stat(filepath.Join(sw.Target, "talks", TemplateDir))
+	assert.True(t, os.IsNotExist(err))
+
+	ioutil.WriteFile(filepath.Join(source, "talks/.template/dir.html"), []byte(`{{.Broken`), 0644)
+	_, err = sw.Scan(source)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "load templates of "+filepath.Join(source, "talks")+" failed")
+	}
+}
@@ -0,0 +1,144 @@
+package swgen
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// Version is the swgen version checked against the themes' min_version
+const Version = "0.2.0"
+
+const (
+	// ThemeConfigFile is the theme description in the theme directory
+	ThemeConfigFile = "theme.yml"
+	// ThemesDir is the output directory of the themes' static assets
+	ThemesDir = "themes"
+)
+
+// Theme is a directory of layouts, partials, static assets and default
+// params shared by sites:
+//
+//	theme.yml          name, min_version and params
+//	layouts/           layouts and layouts/partials, see Templates
+//	static/            copied into themes/<name>/ of the output
+type Theme struct {
+	Name       string
+	Dir        string
+	MinVersion string
+	Params     Params
+}
+
+// themeConfig is the theme.yml, min_version is decoded as written so that
+// 0.10 is not read as the number 0.1
+type themeConfig struct {
+	Name       string                 `yaml:"name"`
+	MinVersion string                 `yaml:"min_version"`
+	Params     map[string]interface{} `yaml:"params"`
+}
+
+// LoadTheme loads the theme in the directory, a relative directory is
+// resolved against the source directory
+func (sw *Swgen) LoadTheme(dir string) (*Theme, error) {
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(sw.Source, dir)
+	}
+
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, fmt.Errorf("load theme failed: %s", err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("theme %s must be a directory", dir)
+	}
+
+	config := &themeConfig{}
+	path := filepath.Join(dir, ThemeConfigFile)
+	data, err := ioutil.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if err := yaml.Unmarshal(data, config); err != nil {
+		return nil, fmt.Errorf("parse %s failed: %s", path, err)
+	}
+
+	theme := &Theme{
+		Name:       config.Name,
+		Dir:        dir,
+		MinVersion: config.MinVersion,
+		Params:     Params{},
+	}
+	if theme.Name == "" {
+		theme.Name = filepath.Base(dir)
+	}
+	if theme.Name == "." || theme.Name == ".." || strings.ContainsAny(theme.Name, `/\`) {
+		return nil, fmt.Errorf("theme name %q must not be a path", theme.Name)
+	}
+	if config.Params != nil {
+		theme.Params = normalize(config.Params).(map[string]interface{})
+	}
+
+	if theme.MinVersion != "" && compareVersions(Version, theme.MinVersion) < 0 {
+		return nil, fmt.Errorf("theme %s requires swgen %s or later, the current version is %s",
+			theme.Name, theme.MinVersion, Version)
+	}
+	return theme, nil
+}
+
+// addTheme adds the theme's layouts and static assets to the templates
+func (ts *Templates) addTheme(theme *Theme) error {
+	if err := ts.addDir(filepath.Join(theme.Dir, "layouts")); err != nil {
+		return err
+	}
+
+	static := filepath.Join(theme.Dir, "static")
+	return filepath.Walk(static, func(path string, info os.FileInfo, err error) error {
+		if os.IsNotExist(err) && path == static {
+			return filepath.SkipDir
+		}
+		if err != nil || info.IsDir() {
+			return err
+		}
+
+		rel, err := filepath.Rel(static, path)
+		if err != nil {
+			return err
+		}
+		name := filepath.Join(ThemesDir, theme.Name, rel)
+		ts.assets[name] = &templateFile{name: name, path: path}
+		return nil
+	})
+}
+
+// themeURL is the URL of the theme's static asset
+func (sw *Swgen) themeURL(path string) (string, error) {
+	if sw.theme == nil {
+		return "", fmt.Errorf("no theme is configured")
+	}
+	return sw.relURL(filepath.ToSlash(filepath.Join(ThemesDir, sw.theme.Name, path))), nil
+}
+
+// compareVersions compares the dotted versions numerically, a missing part
+// is 0
+func compareVersions(a, b string) int {
+	pa := strings.Split(strings.TrimPrefix(a, "v"), ".")
+	pb := strings.Split(strings.TrimPrefix(b, "v"), ".")
+	for i := 0; i < len(pa) || i < len(pb); i++ {
+		var na, nb int
+		if i < len(pa) {
+			na, _ = strconv.Atoi(pa[i])
+		}
+		if i < len(pb) {
+			nb, _ = strconv.Atoi(pb[i])
+		}
+		if na != nb {
+			return na - nb
+		}
+	}
+	return 0
+}
@@ -0,0 +1,268 @@
+package swgen
+
+// AssetsDir is the output directory of the built-in theme's static assets
+const AssetsDir = "_swgen"
+
+// defaultLayouts is the built-in theme, it is the bottom layer of the
+// Templates so that a site overrides some of the layouts and inherits the
+// others. The layouts extend baseof.html through the "title", "head" and
+// "main" blocks. The optional params are read by index so that the layouts
+// work with StrictTemplates.
+var defaultLayouts = map[string]string{
+	BaseLayout: `<!DOCTYPE html>
+<html lang="{{default "en" (index .Site.Params "lang")}}">
+  <head>
+    <meta charset="utf-8" />
+    <meta name="viewport" content="width=device-width, initial-scale=1" />
+    <meta name="color-scheme" content="light dark" />
+    <title>{{block "title" .}}{{.Title}}{{with index .Site.Params "title"}} - {{.}}{{end}}{{end}}</title>
+    <link rel="stylesheet" href="{{relURL "_swgen/swgen.css"}}" />
+    {{.FeedLinks}}
+    {{- range .Alternates}}
+    <link rel="alternate" type="{{.MediaType}}" href="{{.URL}}" />
+    {{- end}}
+    {{- block "head" .}}{{end}}
+  </head>
+  <body>
+    <header>{{partial "nav" .}}</header>
+    <div class="container">
+      {{partial "toc" .}}
+      <main>{{partial "breadcrumb" .}}{{block "main" .}}{{.Page}}{{end}}</main>
+      {{partial "sitenav" .}}
+    </div>
+    <footer>{{partial "footer" .}}</footer>
+  </body>
+</html>
+`,
+	"page.html": `{{define "main"}}
+<article>
+  <h1 class="title">{{.Title}}</h1>
+  {{.Page}}
+</article>
+{{end}}`,
+	"dir.html": `{{define "main"}}
+<section class="dir">
+  {{.Page}}
+</section>
+{{end}}`,
+	"home.html": `{{define "title"}}{{default .Title (index .Site.Params "title")}}{{end}}
+{{define "main"}}
+<section class="home">
+  {{.Page}}
+</section>
+{{end}}`,
+	PartialsDir + "/nav.html": `<nav class="swgen-nav">
+  <a class="home" href="{{.Home.PageURL}}">{{default "Home" (index .Site.Params "title")}}</a>
+  {{with .Up}}<a class="up" href="{{.PageURL}}">&uarr; {{.Title}}</a>{{end}}
+  <span class="pager">
+    {{with .PrevInOrder}}<a class="prev" href="{{.PageURL}}">&larr; {{.Title}}</a>{{end}}
+    {{with .NextInOrder}}<a class="next" href="{{.PageURL}}">{{.Title}} &rarr;</a>{{end}}
+  </span>
+</nav>`,
+	PartialsDir + "/sitenav.html": `{{with .Nav}}{{if .Children}}<aside class="sitenav">
+  {{template "sitenav-items" .Children}}
+</aside>{{end}}{{end}}
+{{define "sitenav-items"}}<ul>
+  {{range .}}<li{{if .InPath}} class="{{if .Active}}active{{else}}open{{end}}"{{end}}>
+    <a href="{{.URL}}"{{if .Active}} aria-current="page"{{end}}>{{.Title}}</a>
+    {{if and .InPath .Children}}{{template "sitenav-items" .Children}}{{end}}
+  </li>
+  {{end}}
+</ul>{{end}}`,
+	PartialsDir + "/breadcrumb.html": `{{with .Ancestors}}<nav class="breadcrumb" aria-label="Breadcrumb">
+  <ol>
+    {{range .}}<li>{{if .HasPage}}<a href="{{.PageURL}}">{{end}}{{if .Up}}{{.Title}}{{else}}{{default "Home" (index $.Site.Params "title")}}{{end}}{{if .HasPage}}</a>{{end}}</li>
+    {{end}}<li aria-current="page">{{$.Title}}</li>
+  </ol>
+</nav>
+<script type="application/ld+json">{{$.BreadcrumbList}}</script>{{end}}`,
+	PartialsDir + "/toc.html": `{{with .Toc}}<aside class="toc">{{.}}</aside>{{end}}`,
+	PartialsDir + "/footer.html": `{{with .Git}}<span class="modified">Last modified {{dateFormat "2006-01-02" .Modified}} by {{.Author}}</span>{{end}}
+{{with .EditURL}}<a class="edit" href="{{.}}">Edit this page</a>{{end}}
+{{with .SourceURL}}<a class="source" href="{{.}}">View source</a>{{end}}`,
+	ShortcodesDir + "/figure.html": `<figure>
+  <img src="{{.Get "src"}}" alt="{{default (.Get "caption") (.Get "alt")}}" />
+  {{with .Get "caption"}}<figcaption>{{.}}</figcaption>{{end}}
+</figure>`,
+	ShortcodesDir + "/callout.html": `<div class="callout callout-{{default "note" (.Get "type")}}">
+  {{with .Get "title"}}<p class="callout-title">{{.}}</p>{{end}}
+  {{.Inner}}
+</div>`,
+	ShortcodesDir + "/youtube.html": `{{$id := default (.Get "id") (.Get 0)}}<div class="youtube">
+  <a href="https://www.youtube.com/watch?v={{$id}}">{{default "Watch on YouTube" (.Get "title")}}</a>
+</div>`,
+	ShortcodesDir + "/children.html": `<ul class="children">
+  {{with .Node.Up}}{{range .Children}}<li><a href="{{.PageURL}}">{{.Title}}</a></li>{{end}}{{end}}
+</ul>`,
+	ListingsDir + "/list.html": `<div class="listing listing-list">
+  {{with index .Params "description"}}<p>{{.}}</p>{{end}}
+  <ul>
+    {{range .Entries}}<li><a href="{{.PageURL}}">{{.Title}}</a></li>
+    {{end}}
+  </ul>
+  {{partial "attachments" .}}
+</div>`,
+	ListingsDir + "/table.html": `<div class="listing listing-table">
+  {{with index .Params "description"}}<p>{{.}}</p>{{end}}
+  <table>
+    <thead><tr><th>Title</th><th>Date</th><th>Summary</th></tr></thead>
+    <tbody>
+      {{range .Entries}}<tr>
+        <td><a href="{{.PageURL}}">{{.Title}}</a></td>
+        <td>{{dateFormat "2006-01-02" .Date}}</td>
+        <td>{{.Summary}}</td>
+      </tr>
+      {{end}}
+    </tbody>
+  </table>
+  {{partial "attachments" .}}
+</div>`,
+	ListingsDir + "/cards.html": `<div class="listing listing-cards">
+  {{with index .Params "description"}}<p>{{.}}</p>{{end}}
+  <div class="cards">
+    {{range .Entries}}<a class="card" href="{{.PageURL}}">
+      <strong>{{.Title}}</strong>
+      <time>{{dateFormat "2006-01-02" .Date}}</time>
+      {{with .Summary}}<p>{{.}}</p>{{end}}
+    </a>
+    {{end}}
+  </div>
+  {{partial "attachments" .}}
+</div>`,
+	PartialsDir + "/attachments.html": `{{with .Attachments}}<ul class="attachments">
+  {{range .}}<li><a href="{{.URL}}">{{.Name}}</a> <span class="size">{{fileSize .Size}}</span></li>
+  {{end}}
+</ul>{{end}}`,
+}
+
+// defaultAssets is the static files of the built-in theme, they are copied
+// into AssetsDir of the output
+var defaultAssets = map[string]string{
+	"swgen.css": `:root {
+  --fg: #222;
+  --bg: #fff;
+  --muted: #666;
+  --link: #0b62c4;
+  --border: #e3e3e3;
+  --code-bg: #f5f5f5;
+}
+
+@media (prefers-color-scheme: dark) {
+  :root {
+    --fg: #ddd;
+    --bg: #181a1b;
+    --muted: #999;
+    --link: #6cb0ff;
+    --border: #333;
+    --code-bg: #23262a;
+  }
+}
+
+body {
+  margin: 0;
+  color: var(--fg);
+  background: var(--bg);
+  font: 16px/1.6 -apple-system, "Segoe UI", Helvetica, Arial, sans-serif;
+}
+
+a { color: var(--link); text-decoration: none; }
+a:hover { text-decoration: underline; }
+
+header, footer {
+  padding: 0.5em 1em;
+  border-color: var(--border);
+  border-style: solid;
+  border-width: 0 0 1px 0;
+}
+
+footer {
+  border-width: 1px 0 0 0;
+  color: var(--muted);
+  font-size: 0.9em;
+}
+
+footer a { margin-left: 1em; }
+
+.swgen-nav { display: flex; gap: 1em; align-items: baseline; }
+.swgen-nav .home { font-weight: bold; }
+.swgen-nav .pager { margin-left: auto; display: flex; gap: 1em; }
+
+.container {
+  display: flex;
+  flex-direction: row-reverse;
+  max-width: 72em;
+  margin: 0 auto;
+  padding: 0 1em;
+}
+
+main { flex: 1; min-width: 0; }
+
+.toc {
+  width: 16em;
+  margin-left: 2em;
+  padding-top: 1em;
+  font-size: 0.9em;
+}
+
+.toc ul { list-style: none; padding-left: 1em; }
+
+.breadcrumb ol { list-style: none; padding: 0; margin: 1em 0 0; font-size: 0.9em; }
+.breadcrumb li { display: inline; }
+.breadcrumb li + li::before { content: "/"; margin: 0 0.4em; color: var(--muted); }
+
+.sitenav {
+  width: 14em;
+  margin-right: 2em;
+  padding-top: 1em;
+  font-size: 0.9em;
+}
+.sitenav ul { list-style: none; padding-left: 1em; }
+.sitenav .active > a { font-weight: bold; color: var(--fg); }
+
+pre, code { background: var(--code-bg); font-family: Menlo, Consolas, monospace; }
+pre { padding: 0.8em; overflow-x: auto; }
+
+table { border-collapse: collapse; }
+th, td { border: 1px solid var(--border); padding: 0.3em 0.6em; }
+
+img { max-width: 100%; }
+
+figure { margin: 1em 0; text-align: center; }
+figcaption { color: var(--muted); font-size: 0.9em; }
+
+.callout {
+  margin: 1em 0;
+  padding: 0.5em 1em;
+  border-left: 4px solid var(--link);
+  background: var(--code-bg);
+}
+.callout-warning { border-color: #d9822b; }
+.callout-danger { border-color: #d33; }
+.callout-title { font-weight: bold; margin: 0.3em 0; }
+
+.listing-cards .cards {
+  display: grid;
+  grid-template-columns: repeat(auto-fill, minmax(14em, 1fr));
+  gap: 1em;
+}
+.card {
+  display: block;
+  padding: 0.8em;
+  border: 1px solid var(--border);
+  color: var(--fg);
+}
+.card time, .attachments .size { color: var(--muted); font-size: 0.9em; }
+.card p { margin: 0.3em 0 0; }
+
+.youtube {
+  padding: 2em;
+  text-align: center;
+  border: 1px dashed var(--border);
+}
+
+@media (max-width: 48em) {
+  .container { flex-direction: column; }
+  .toc, .sitenav { width: auto; margin: 0; }
+}
+`,
+}
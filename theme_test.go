@@ -0,0 +1,79 @@
+package swgen
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTheme(t *testing.T) {
+	dir := makeSite(t, map[string]string{
+		"paper/theme.yml":                   "name: paper\nmin_version: 0.1\nparams:\n  color: blue\n  title: Paper\n",
+		"paper/layouts/page.html":           `{{define "main"}}paper {{.Site.Params.color}} {{partial "meta" .}}{{end}}`,
+		"paper/layouts/partials/meta.html":  `<link href="{{themeURL "css/paper.css"}}" />`,
+		"paper/static/css/paper.css":        "body {}",
+		"site/.template/partials/meta.html": `site meta`,
+		"site/notes/a.html":                 "<p>a</p>",
+		"future/theme.yml":                  "min_version: 99.0\n",
+		"unquoted/theme.yml":                "min_version: 0.10\n",
+		"escape/theme.yml":                  "name: ../../escape\n",
+	})
+	defer os.RemoveAll(dir)
+
+	source := filepath.Join(dir, "site")
+	sw := &Swgen{
+		Source: source,
+		Target: filepath.Join(source, "testing"),
+		Ignore: &dummyIgnore{},
+		Params: map[string]interface{}{"title": "Site"},
+		Theme:  "../paper",
+	}
+	var err error
+	sw.Templates, err = sw.LoadTemplates(filepath.Join(source, ".template"))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"title": "Site", "color": "blue"}, sw.Params)
+
+	assert.NoError(t, sw.Run())
+	page, err := ioutil.ReadFile(filepath.Join(sw.Target, "notes/a.html.html"))
+	assert.NoError(t, err)
+	assert.Contains(t, string(page), "paper blue site meta")
+	css, err := ioutil.ReadFile(filepath.Join(sw.Target, ThemesDir, "paper/css/paper.css"))
+	assert.NoError(t, err)
+	assert.Equal(t, "body {}", string(css))
+
+	url, err := sw.themeURL("css/paper.css")
+	assert.NoError(t, err)
+	assert.Equal(t, "/themes/paper/css/paper.css", url)
+
+	sw.Theme = filepath.Join(dir, "future")
+	_, err = sw.LoadTemplates()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "requires swgen 99")
+	}
+
+	// 0.10 is a later version than 0.2, not the number 0.1
+	sw.Theme = filepath.Join(dir, "unquoted")
+	_, err = sw.LoadTemplates()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "requires swgen 0.10")
+	}
+
+	sw.Theme = filepath.Join(dir, "escape")
+	_, err = sw.LoadTemplates()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "must not be a path")
+	}
+
+	sw.Theme = "missing"
+	_, err = sw.LoadTemplates()
+	assert.Error(t, err)
+}
+
+func TestCompareVersions(t *testing.T) {
+	assert.True(t, compareVersions("0.2.0", "0.10") < 0)
+	assert.True(t, compareVersions("v1.0", "0.9.9") > 0)
+	assert.Equal(t, 0, compareVersions("1.0", "1.0.0"))
+}
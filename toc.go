@@ -0,0 +1,115 @@
+package swgen
+
+import (
+	"fmt"
+	"html"
+	"html/template"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// default heading levels of the table of contents
+const (
+	DefaultTocMin = 2
+	DefaultTocMax = 3
+)
+
+// TocEntry is a heading of the page, the headings of the lower levels
+// following it are its children
+type TocEntry struct {
+	Level    int
+	ID       string
+	Title    string
+	Children []*TocEntry
+}
+
+var (
+	headingTag = regexp.MustCompile(`(?s)<h([1-6])(\s[^>]*)?>(.*?)</h([1-6])>`)
+	idAttr     = regexp.MustCompile(`\sid=["']([^"']*)["']`)
+)
+
+// Toc builds the table of contents of the rendered page from the headings
+// between the toc_min and toc_max levels, the headings without an id get
+// one from their text. It returns the page with the ids, the contents as a
+// nested list and as entries. The levels come from the params of the page,
+// its directories or the site, "toc: false" turns the contents off.
+func (n *Node) Toc(page template.HTML) (template.HTML, template.HTML, []*TocEntry) {
+	min, max := DefaultTocMin, DefaultTocMax
+	if p := n.inheritedParams("toc_min"); p != nil {
+		min = p.Int("toc_min")
+	}
+	if p := n.inheritedParams("toc_max"); p != nil {
+		max = p.Int("toc_max")
+	}
+
+	ids := map[string]bool{}
+	for _, m := range idAttr.FindAllStringSubmatch(string(page), -1) {
+		ids[m[1]] = true
+	}
+
+	root := &TocEntry{}
+	stack := []*TocEntry{root}
+	output := headingTag.ReplaceAllStringFunc(string(page), func(tag string) string {
+		m := headingTag.FindStringSubmatch(tag)
+		if m[1] != m[4] {
+			return tag
+		}
+		level, _ := strconv.Atoi(m[1])
+		attrs, inner := m[2], m[3]
+		text := strings.Join(strings.Fields(plainify(inner)), " ")
+
+		id := ""
+		if m := idAttr.FindStringSubmatch(attrs); m != nil {
+			id = m[1]
+		} else {
+			id = uniqueID(Slugify(html.UnescapeString(text)), ids)
+			tag = fmt.Sprintf(`<h%d id="%s"%s>%s</h%d>`, level, id, attrs, inner, level)
+		}
+
+		if level < min || level > max {
+			return tag
+		}
+		for len(stack) > 1 && stack[len(stack)-1].Level >= level {
+			stack = stack[:len(stack)-1]
+		}
+		entry := &TocEntry{Level: level, ID: id, Title: html.UnescapeString(text)}
+		parent := stack[len(stack)-1]
+		parent.Children = append(parent.Children, entry)
+		stack = append(stack, entry)
+		return tag
+	})
+
+	if p := n.inheritedParams("toc"); p != nil && !p.Bool("toc") {
+		return template.HTML(output), "", nil
+	}
+	return template.HTML(output), tocHTML(root.Children), root.Children
+}
+
+// uniqueID is the slug, or the slug with a number suffix if it is taken
+func uniqueID(slug string, ids map[string]bool) string {
+	if slug == "" {
+		slug = "section"
+	}
+	id := slug
+	for i := 1; ids[id]; i++ {
+		id = fmt.Sprintf("%s-%d", slug, i)
+	}
+	ids[id] = true
+	return id
+}
+
+func tocHTML(entries []*TocEntry) template.HTML {
+	if len(entries) == 0 {
+		return ""
+	}
+
+	sb := &strings.Builder{}
+	sb.WriteString("<ul>")
+	for _, e := range entries {
+		fmt.Fprintf(sb, `<li><a href="#%s">%s</a>%s</li>`,
+			template.HTMLEscapeString(e.ID), template.HTMLEscapeString(e.Title), tocHTML(e.Children))
+	}
+	sb.WriteString("</ul>")
+	return template.HTML(sb.String())
+}
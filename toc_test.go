@@ -0,0 +1,69 @@
+package swgen
+
+import (
+	"html/template"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestToc(t *testing.T) {
+	page := template.HTML(`<h1>Title</h1>
+<h2>Install &amp; Run</h2>
+<h3 class="x">Linux</h3>
+<h4>Deep</h4>
+<h3 id="mac">macOS</h3>
+<h2>Usage</h2>
+<h2>Usage</h2>`)
+
+	sw := &Swgen{}
+	dir := &Node{Swgen: sw, Params: Params{}}
+	n := &Node{Swgen: sw, Params: Params{}, Up: dir}
+	output, toc, entries := n.Toc(page)
+	assert.Contains(t, string(output), `<h2 id="install-run">Install &amp; Run</h2>`)
+	assert.Contains(t, string(output), `<h3 id="linux" class="x">Linux</h3>`)
+	assert.Contains(t, string(output), `<h3 id="mac">macOS</h3>`)
+	assert.Contains(t, string(output), `<h2 id="usage-1">Usage</h2>`)
+	assert.Equal(t, `<ul><li><a href="#install-run">Install &amp; Run</a>`+
+		`<ul><li><a href="#linux">Linux</a></li><li><a href="#mac">macOS</a></li></ul></li>`+
+		`<li><a href="#usage">Usage</a></li><li><a href="#usage-1">Usage</a></li></ul>`, string(toc))
+	if assert.Len(t, entries, 3) {
+		assert.Equal(t, "Install & Run", entries[0].Title)
+		assert.Len(t, entries[0].Children, 2)
+	}
+
+	// the directory config sets the levels
+	dir.Params["toc_min"] = 3
+	dir.Params["toc_max"] = 4
+	_, _, entries = n.Toc(page)
+	if assert.Len(t, entries, 2) {
+		assert.Equal(t, "deep", entries[0].Children[0].ID)
+	}
+
+	n.Params["toc"] = false
+	output, toc, entries = n.Toc(page)
+	assert.Contains(t, string(output), `id="usage"`)
+	assert.Empty(t, toc)
+	assert.Empty(t, entries)
+}
+
+func TestTocLayout(t *testing.T) {
+	source := makeSite(t, map[string]string{
+		"a.html": "<h2>One</h2><p>1</p><h2>Two</h2>",
+	})
+	defer os.RemoveAll(source)
+
+	sw := &Swgen{Source: source, Target: filepath.Join(source, "testing"), Ignore: &dummyIgnore{}}
+	var err error
+	sw.Templates, err = sw.LoadTemplates()
+	assert.NoError(t, err)
+	assert.NoError(t, sw.Run())
+
+	content, err := ioutil.ReadFile(filepath.Join(sw.Target, "a.html.html"))
+	assert.NoError(t, err)
+	assert.Contains(t, string(content), `<aside class="toc"><ul><li><a href="#one">One</a></li><li><a href="#two">Two</a></li></ul></aside>`)
+	assert.Contains(t, string(content), `<h2 id="one">One</h2>`)
+}